@@ -2,29 +2,60 @@ package balancer
 
 import (
 	"sync"
+	"time"
 )
 
 // Backend представляет сервер с флагом доступности и количеством активных соединений.
 type Backend struct {
 	URL               string
+	Weight            int // относительный вес для взвешенных стратегий
 	Alive             bool
 	ActiveConnections int
 	mu                sync.RWMutex
+	health            healthState
+	latency           latencyState
+	outlier           outlierState
+	breaker           *CircuitBreaker // nil — выключатель не используется
 }
 
-// NewBackend создает новый экземпляр Backend.
+// NewBackend создает новый экземпляр Backend с весом 1.
 func NewBackend(url string) *Backend {
+	return NewWeightedBackend(url, 1)
+}
+
+// NewWeightedBackend создает новый экземпляр Backend с заданным весом.
+// Вес меньше 1 приводится к 1.
+func NewWeightedBackend(url string, weight int) *Backend {
+	if weight < 1 {
+		weight = 1
+	}
 	return &Backend{
-		URL:   url,
-		Alive: true,
+		URL:    url,
+		Weight: weight,
+		Alive:  true,
+		health: healthState{policy: DefaultHealthPolicy()},
+	}
+}
+
+// GetWeight возвращает вес бэкенда (не меньше 1).
+func (b *Backend) GetWeight() int {
+	if b.Weight < 1 {
+		return 1
 	}
+	return b.Weight
 }
 
-// SetAlive обновляет статус доступности.
+// SetAlive принудительно обновляет статус доступности, минуя пороги HealthPolicy.
 func (b *Backend) SetAlive(alive bool) {
 	b.mu.Lock()
 	defer b.mu.Unlock()
+	if b.Alive != alive {
+		b.health.lastTransition = time.Now()
+		b.health.lastReason = "forced"
+	}
 	b.Alive = alive
+	b.health.successes = 0
+	b.health.failures = 0
 }
 
 // IsAlive возвращает текущий статус доступности.
@@ -34,6 +65,116 @@ func (b *Backend) IsAlive() bool {
 	return b.Alive
 }
 
+// SetHealthPolicy задаёт пороги переключения состояния бэкенда.
+func (b *Backend) SetHealthPolicy(p HealthPolicy) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.health.policy = p.normalized()
+	b.health.trim()
+}
+
+// ReportHealth учитывает результат проверки и меняет статус Alive, только если
+// набрано нужное число подряд идущих результатов и истёк HoldDown с прошлой смены.
+// Возвращает true, если статус изменился.
+func (b *Backend) ReportHealth(ok bool, reason string) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now()
+	h := &b.health
+	h.record(HealthResult{Time: now, OK: ok, Reason: reason})
+
+	if ok {
+		h.successes++
+		h.failures = 0
+	} else {
+		h.failures++
+		h.successes = 0
+	}
+
+	var next bool
+	switch {
+	case !b.Alive && ok && h.successes >= h.policy.RiseThreshold:
+		next = true
+	case b.Alive && !ok && h.failures >= h.policy.FallThreshold:
+		next = false
+	default:
+		return false
+	}
+
+	// Подавление флаппинга: не чаще одной смены состояния за HoldDown
+	if !h.lastTransition.IsZero() && now.Sub(h.lastTransition) < h.policy.HoldDown {
+		return false
+	}
+
+	b.Alive = next
+	h.lastTransition = now
+	h.lastReason = reason
+	return true
+}
+
+// HealthStatus возвращает снимок состояния здоровья бэкенда.
+func (b *Backend) HealthStatus() HealthStatus {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	history := make([]HealthResult, len(b.health.history))
+	copy(history, b.health.history)
+	return HealthStatus{
+		URL:                  b.URL,
+		Alive:                b.Alive,
+		ConsecutiveSuccesses: b.health.successes,
+		ConsecutiveFailures:  b.health.failures,
+		LastTransition:       b.health.lastTransition,
+		LastReason:           b.health.lastReason,
+		History:              history,
+		Ejected:              time.Now().Before(b.outlier.ejectedUntil),
+		EjectedUntil:         b.outlier.ejectedUntil,
+		Ejections:            b.outlier.ejections,
+		Breaker:              b.breakerState().String(),
+	}
+}
+
+// SetCircuitBreaker подключает к бэкенду автоматический выключатель.
+func (b *Backend) SetCircuitBreaker(cb *CircuitBreaker) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.breaker = cb
+}
+
+// BreakerState возвращает состояние выключателя бэкенда (closed, если его нет).
+func (b *Backend) BreakerState() BreakerState {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.breakerState()
+}
+
+// breakerState вызывается под b.mu.
+func (b *Backend) breakerState() BreakerState {
+	if b.breaker == nil {
+		return BreakerClosed
+	}
+	return b.breaker.State()
+}
+
+// AllowRequest спрашивает выключатель, можно ли отправить запрос на бэкенд.
+// Вызывается для бэкендов, выбранных в обход ServerPool.Select.
+func (b *Backend) AllowRequest() bool {
+	b.mu.RLock()
+	cb := b.breaker
+	b.mu.RUnlock()
+	return cb == nil || cb.Allow()
+}
+
+// recordOutcome передаёт результат запроса выключателю.
+func (b *Backend) recordOutcome(outcome Outcome) {
+	b.mu.RLock()
+	cb := b.breaker
+	b.mu.RUnlock()
+	if cb != nil {
+		cb.Record(outcome)
+	}
+}
+
 // IncConnections увеличивает количество активных соединений.
 func (b *Backend) IncConnections() {
 	b.mu.Lock()
@@ -0,0 +1,260 @@
+package balancer
+
+import (
+	"sync"
+	"time"
+)
+
+// BreakerState — состояние автоматического выключателя бэкенда.
+type BreakerState int
+
+const (
+	BreakerClosed   BreakerState = iota // запросы идут, ошибки считаются
+	BreakerOpen                         // запросы не идут до истечения OpenTimeout
+	BreakerHalfOpen                     // пропускается HalfOpenRequests пробных запросов
+)
+
+// String возвращает имя состояния для логов.
+func (s BreakerState) String() string {
+	switch s {
+	case BreakerClosed:
+		return "closed"
+	case BreakerOpen:
+		return "open"
+	case BreakerHalfOpen:
+		return "half_open"
+	default:
+		return "unknown"
+	}
+}
+
+// BreakerConfig задаёт параметры выключателя.
+type BreakerConfig struct {
+	Window           time.Duration // длина скользящего окна подсчёта ошибок
+	Buckets          int           // число интервалов, на которые делится окно
+	MinRequests      int           // минимум запросов в окне для оценки доли ошибок
+	ErrorThreshold   float64       // доля ошибок (0..1), при которой выключатель размыкается
+	OpenTimeout      time.Duration // сколько выключатель остаётся разомкнутым
+	HalfOpenRequests int           // пробных запросов в полуоткрытом состоянии
+}
+
+// DefaultBreakerConfig возвращает параметры по умолчанию.
+func DefaultBreakerConfig() BreakerConfig {
+	return BreakerConfig{
+		Window:           10 * time.Second,
+		Buckets:          10,
+		MinRequests:      20,
+		ErrorThreshold:   0.5,
+		OpenTimeout:      30 * time.Second,
+		HalfOpenRequests: 3,
+	}
+}
+
+// normalized подставляет значения по умолчанию вместо нулевых.
+func (c BreakerConfig) normalized() BreakerConfig {
+	def := DefaultBreakerConfig()
+	if c.Window <= 0 {
+		c.Window = def.Window
+	}
+	if c.Buckets <= 0 {
+		c.Buckets = def.Buckets
+	}
+	if c.MinRequests <= 0 {
+		c.MinRequests = def.MinRequests
+	}
+	if c.ErrorThreshold <= 0 || c.ErrorThreshold > 1 {
+		c.ErrorThreshold = def.ErrorThreshold
+	}
+	if c.OpenTimeout <= 0 {
+		c.OpenTimeout = def.OpenTimeout
+	}
+	if c.HalfOpenRequests <= 0 {
+		c.HalfOpenRequests = def.HalfOpenRequests
+	}
+	return c
+}
+
+// CircuitBreaker — выключатель бэкенда, управляемый долей ошибок проксированных
+// запросов в скользящем окне. OnStateChange вызывается при каждой смене состояния.
+type CircuitBreaker struct {
+	cfg           BreakerConfig
+	OnStateChange func(from, to BreakerState)
+
+	mu        sync.Mutex
+	state     BreakerState
+	openedAt  time.Time
+	window    rollingWindow
+	admitted  int // пробных запросов пропущено в полуоткрытом состоянии
+	successes int // из них успешных
+}
+
+// NewCircuitBreaker создаёт замкнутый выключатель.
+func NewCircuitBreaker(cfg BreakerConfig) *CircuitBreaker {
+	cfg = cfg.normalized()
+	return &CircuitBreaker{
+		cfg:    cfg,
+		window: newRollingWindow(cfg.Window, cfg.Buckets),
+	}
+}
+
+// State возвращает текущее состояние выключателя.
+func (cb *CircuitBreaker) State() BreakerState {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	return cb.state
+}
+
+// Ready сообщает, может ли бэкенд сейчас принять запрос. Не расходует пробные
+// запросы, поэтому подходит для фильтрации кандидатов в стратегиях.
+func (cb *CircuitBreaker) Ready() bool {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	switch cb.state {
+	case BreakerOpen:
+		return time.Since(cb.openedAt) >= cb.cfg.OpenTimeout
+	case BreakerHalfOpen:
+		return cb.admitted < cb.cfg.HalfOpenRequests
+	default:
+		return true
+	}
+}
+
+// Allow решает, пропустить ли запрос к бэкенду, и в полуоткрытом состоянии
+// расходует один пробный запрос. Результат пропущенного запроса передаётся в Record.
+func (cb *CircuitBreaker) Allow() bool {
+	cb.mu.Lock()
+	from := cb.state
+	allowed := true
+	switch cb.state {
+	case BreakerOpen:
+		if time.Since(cb.openedAt) < cb.cfg.OpenTimeout {
+			allowed = false
+			break
+		}
+		cb.setState(BreakerHalfOpen, time.Now())
+		fallthrough
+	case BreakerHalfOpen:
+		if cb.admitted >= cb.cfg.HalfOpenRequests {
+			allowed = false
+			break
+		}
+		cb.admitted++
+	}
+	to := cb.state
+	cb.mu.Unlock()
+
+	cb.notify(from, to)
+	return allowed
+}
+
+// Record учитывает результат запроса. Отменённый запрос ничего не говорит о
+// бэкенде: он не считается в окне, а в полуоткрытом состоянии возвращает
+// пробный запрос, чтобы выключатель мог замкнуться.
+func (cb *CircuitBreaker) Record(outcome Outcome) {
+	if outcome == OutcomeCancelled {
+		cb.mu.Lock()
+		if cb.state == BreakerHalfOpen && cb.admitted > 0 {
+			cb.admitted--
+		}
+		cb.mu.Unlock()
+		return
+	}
+
+	failed := outcome != OutcomeSuccess
+	now := time.Now()
+
+	cb.mu.Lock()
+	from := cb.state
+	switch cb.state {
+	case BreakerClosed:
+		cb.window.add(now, failed)
+		total, errors := cb.window.counts(now)
+		if total >= cb.cfg.MinRequests && float64(errors) >= cb.cfg.ErrorThreshold*float64(total) {
+			cb.setState(BreakerOpen, now)
+		}
+	case BreakerHalfOpen:
+		if failed {
+			cb.setState(BreakerOpen, now)
+			break
+		}
+		cb.successes++
+		if cb.successes >= cb.cfg.HalfOpenRequests {
+			cb.setState(BreakerClosed, now)
+		}
+	}
+	// В разомкнутом состоянии запоздавшие результаты игнорируются
+	to := cb.state
+	cb.mu.Unlock()
+
+	cb.notify(from, to)
+}
+
+// setState переводит выключатель в новое состояние. Вызывается под cb.mu.
+func (cb *CircuitBreaker) setState(state BreakerState, now time.Time) {
+	cb.state = state
+	cb.admitted = 0
+	cb.successes = 0
+	switch state {
+	case BreakerOpen:
+		cb.openedAt = now
+	case BreakerClosed:
+		cb.window.reset()
+	}
+}
+
+// notify вызывает OnStateChange вне блокировки.
+func (cb *CircuitBreaker) notify(from, to BreakerState) {
+	if from != to && cb.OnStateChange != nil {
+		cb.OnStateChange(from, to)
+	}
+}
+
+// rollingWindow считает запросы и ошибки за последние Window, разбитые на интервалы.
+type rollingWindow struct {
+	width   time.Duration
+	buckets []windowBucket
+}
+
+type windowBucket struct {
+	start  time.Time
+	total  int
+	errors int
+}
+
+func newRollingWindow(window time.Duration, buckets int) rollingWindow {
+	return rollingWindow{
+		width:   window / time.Duration(buckets),
+		buckets: make([]windowBucket, buckets),
+	}
+}
+
+// add учитывает запрос в интервале, соответствующем now.
+func (w *rollingWindow) add(now time.Time, failed bool) {
+	start := now.Truncate(w.width)
+	b := &w.buckets[int(start.UnixNano()/int64(w.width))%len(w.buckets)]
+	if !b.start.Equal(start) {
+		*b = windowBucket{start: start}
+	}
+	b.total++
+	if failed {
+		b.errors++
+	}
+}
+
+// counts возвращает число запросов и ошибок в интервалах, попадающих в окно.
+func (w *rollingWindow) counts(now time.Time) (total, errors int) {
+	window := w.width * time.Duration(len(w.buckets))
+	for _, b := range w.buckets {
+		if !b.start.IsZero() && now.Sub(b.start) < window {
+			total += b.total
+			errors += b.errors
+		}
+	}
+	return total, errors
+}
+
+func (w *rollingWindow) reset() {
+	for i := range w.buckets {
+		w.buckets[i] = windowBucket{}
+	}
+}
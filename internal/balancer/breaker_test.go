@@ -0,0 +1,151 @@
+package balancer
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCircuitBreaker_OpensOnErrorRate(t *testing.T) {
+	cb := NewCircuitBreaker(BreakerConfig{MinRequests: 4, ErrorThreshold: 0.5, OpenTimeout: time.Minute})
+
+	cb.Record(OutcomeSuccess)
+	cb.Record(OutcomeError)
+	cb.Record(OutcomeSuccess)
+	if cb.State() != BreakerClosed {
+		t.Fatal("breaker opened before min requests")
+	}
+
+	cb.Record(OutcomeGatewayFailure)
+	if cb.State() != BreakerOpen {
+		t.Fatalf("expected breaker to open at 50%% errors, got %s", cb.State())
+	}
+	if cb.Ready() || cb.Allow() {
+		t.Error("open breaker must not let requests through")
+	}
+}
+
+func TestCircuitBreaker_HalfOpenProbing(t *testing.T) {
+	var transitions []string
+	cb := NewCircuitBreaker(BreakerConfig{MinRequests: 1, OpenTimeout: 20 * time.Millisecond, HalfOpenRequests: 2})
+	cb.OnStateChange = func(from, to BreakerState) {
+		transitions = append(transitions, from.String()+"->"+to.String())
+	}
+
+	cb.Record(OutcomeError)
+	time.Sleep(25 * time.Millisecond)
+
+	// В полуоткрытом состоянии проходят только два пробных запроса
+	if !cb.Allow() || !cb.Allow() {
+		t.Fatal("expected trial requests to be allowed after open timeout")
+	}
+	if cb.State() != BreakerHalfOpen {
+		t.Fatalf("expected half-open state, got %s", cb.State())
+	}
+	if cb.Ready() || cb.Allow() {
+		t.Fatal("expected no more than 2 trial requests")
+	}
+
+	cb.Record(OutcomeSuccess)
+	cb.Record(OutcomeSuccess)
+	if cb.State() != BreakerClosed {
+		t.Fatalf("expected breaker to close after successful trials, got %s", cb.State())
+	}
+
+	want := []string{"closed->open", "open->half_open", "half_open->closed"}
+	if len(transitions) != len(want) {
+		t.Fatalf("unexpected transitions: %v", transitions)
+	}
+	for i := range want {
+		if transitions[i] != want[i] {
+			t.Errorf("transition %d: got %s, want %s", i, transitions[i], want[i])
+		}
+	}
+}
+
+func TestCircuitBreaker_HalfOpenFailureReopens(t *testing.T) {
+	cb := NewCircuitBreaker(BreakerConfig{MinRequests: 1, OpenTimeout: 10 * time.Millisecond})
+	cb.Record(OutcomeError)
+	time.Sleep(15 * time.Millisecond)
+
+	if !cb.Allow() {
+		t.Fatal("expected trial request to be allowed")
+	}
+	cb.Record(OutcomeError)
+	if cb.State() != BreakerOpen {
+		t.Fatalf("expected failed trial to reopen breaker, got %s", cb.State())
+	}
+}
+
+func TestCircuitBreaker_CancelledTrialIsReleased(t *testing.T) {
+	cb := NewCircuitBreaker(BreakerConfig{MinRequests: 1, OpenTimeout: 10 * time.Millisecond, HalfOpenRequests: 1})
+	cb.Record(OutcomeError)
+	time.Sleep(15 * time.Millisecond)
+
+	if !cb.Allow() || cb.Ready() {
+		t.Fatal("expected the only trial request to be taken")
+	}
+	// Отменённый пробный запрос не считается ни успехом, ни ошибкой
+	cb.Record(OutcomeCancelled)
+	if cb.State() != BreakerHalfOpen || !cb.Ready() {
+		t.Fatalf("expected cancelled trial to be given back, got %s", cb.State())
+	}
+	if !cb.Allow() {
+		t.Fatal("expected a new trial request to be allowed")
+	}
+	cb.Record(OutcomeSuccess)
+	if cb.State() != BreakerClosed {
+		t.Fatalf("expected breaker to close after a successful trial, got %s", cb.State())
+	}
+}
+
+func TestCircuitBreaker_ClientAbortsAreNotCounted(t *testing.T) {
+	cb := NewCircuitBreaker(BreakerConfig{MinRequests: 2, ErrorThreshold: 0.5, OpenTimeout: time.Minute})
+
+	// Запросы, прерванные клиентом, не попадают в окно
+	for i := 0; i < 5; i++ {
+		cb.Record(OutcomeCancelled)
+	}
+	if cb.State() != BreakerClosed {
+		t.Fatalf("expected client aborts to keep breaker closed, got %s", cb.State())
+	}
+	// ...и не разбавляют ошибки: одна ошибка из двух учтённых запросов размыкает
+	cb.Record(OutcomeSuccess)
+	cb.Record(OutcomeError)
+	if cb.State() != BreakerOpen {
+		t.Fatalf("expected breaker to open on 1 of 2 counted requests, got %s", cb.State())
+	}
+}
+
+func TestCircuitBreaker_WindowExpires(t *testing.T) {
+	cb := NewCircuitBreaker(BreakerConfig{Window: 40 * time.Millisecond, Buckets: 4, MinRequests: 3, ErrorThreshold: 0.5})
+
+	cb.Record(OutcomeError)
+	cb.Record(OutcomeError)
+	time.Sleep(50 * time.Millisecond)
+
+	// Старые ошибки вышли из окна: одна ошибка из трёх свежих запросов не размыкает
+	cb.Record(OutcomeSuccess)
+	cb.Record(OutcomeSuccess)
+	cb.Record(OutcomeError)
+	if cb.State() != BreakerClosed {
+		t.Fatalf("expected errors outside window to be forgotten, got %s", cb.State())
+	}
+}
+
+func TestPoolSkipsOpenBreaker(t *testing.T) {
+	pool := NewServerPool([]string{"http://a", "http://b"})
+	pool.SetStrategy(NewRoundRobinStrategy())
+	pool.SetCircuitBreakers(BreakerConfig{MinRequests: 1, OpenTimeout: time.Minute})
+
+	bad := pool.AllBackends()[0]
+	pool.Done(bad, OutcomeError, 0)
+	if bad.BreakerState() != BreakerOpen || bad.HealthStatus().Breaker != "open" {
+		t.Fatalf("expected breaker of http://a to be open, got %s", bad.BreakerState())
+	}
+
+	for i := 0; i < 4; i++ {
+		if pool.NextBackend() == bad {
+			t.Fatal("backend with open breaker was selected")
+		}
+	}
+}
@@ -2,50 +2,115 @@ package balancer
 
 import (
 	"context"
+	"fmt"
 	"net/http"
 	"time"
+)
 
+const (
+	// DefaultHealthCheckInterval — период проверок, если Interval не задан.
+	DefaultHealthCheckInterval = 10 * time.Second
+	// DefaultHealthCheckPath — путь health check по умолчанию.
+	DefaultHealthCheckPath = "/healthz"
+	// DefaultHealthCheckTimeout — таймаут одной проверки по умолчанию.
+	DefaultHealthCheckTimeout = 2 * time.Second
 )
 
 // Checker выполняет периодические health checks для бэкендов.
 type Checker struct {
-	Backends []*Backend
-	Interval time.Duration
-	Client   *http.Client
+	Pool           *ServerPool // пул, список бэкендов которого читается на каждом тике (nil — Backends)
+	Backends       []*Backend
+	Interval       time.Duration
+	Client         *http.Client
+	Path           string // путь, по которому выполняется проверка
+	ExpectedStatus int    // ожидаемый код ответа
+	GRPC           bool   // проверять по протоколу grpc.health.v1 вместо GET Path
+	GRPCService    string // сервис в запросе Check ("" — сервер целиком)
 }
 
 // NewChecker создаёт новый Checker с заданным списком бэкендов и интервалом.
 func NewChecker(backends []*Backend, interval time.Duration) *Checker {
 	return &Checker{
-		Backends: backends,
-		Interval: interval,
-		Client:   &http.Client{Timeout: 2 * time.Second},
+		Backends:       backends,
+		Interval:       interval,
+		Client:         &http.Client{Timeout: DefaultHealthCheckTimeout},
+		Path:           DefaultHealthCheckPath,
+		ExpectedStatus: http.StatusOK,
 	}
 }
 
+// NewPoolChecker создаёт Checker для бэкендов пула: бэкенды, добавленные в
+// пул позже (AddBackend), проверяются со следующего тика.
+func NewPoolChecker(pool *ServerPool, interval time.Duration) *Checker {
+	c := NewChecker(nil, interval)
+	c.Pool = pool
+	return c
+}
+
 // Run запускает цикл проверок доступности бэкендов до отмены контекста.
+// Первая проверка выполняется сразу, не дожидаясь первого тика.
 func (c *Checker) Run(ctx context.Context) {
-	ticker := time.NewTicker(c.Interval)
+	interval := c.Interval
+	if interval <= 0 {
+		interval = DefaultHealthCheckInterval
+	}
+	ticker := time.NewTicker(interval)
 	defer ticker.Stop()
 
+	c.checkAll(ctx)
 	for {
 		select {
 		case <-ticker.C:
-			for _, b := range c.Backends {
-				go c.checkBackend(b)
-			}
+			c.checkAll(ctx)
 		case <-ctx.Done():
 			return
 		}
 	}
 }
 
-// checkBackend отправляет GET-запрос на /healthz и обновляет статус Alive у бэкенда.
-func (c *Checker) checkBackend(b *Backend) {
-	resp, err := c.Client.Get(b.URL + "/healthz")
-	alive := err == nil && resp.StatusCode == http.StatusOK
-	b.SetAlive(alive)
-	if resp != nil {
-		resp.Body.Close()
+// checkAll запускает проверку каждого бэкенда в отдельной горутине.
+func (c *Checker) checkAll(ctx context.Context) {
+	backends := c.Backends
+	if c.Pool != nil {
+		backends = c.Pool.AllBackends()
+	}
+	for _, b := range backends {
+		go c.checkBackend(ctx, b)
+	}
+}
+
+// checkBackend отправляет GET-запрос на Path (или вызов Health/Check при
+// GRPC) и передаёт результат бэкенду.
+// Статус Alive меняется с учётом порогов HealthPolicy бэкенда, поэтому
+// восстановившийся бэкенд возвращается в пул после RiseThreshold успешных проверок.
+func (c *Checker) checkBackend(ctx context.Context, b *Backend) {
+	if c.GRPC {
+		if err := c.probeGRPC(ctx, b); err != nil {
+			if ctx.Err() == nil {
+				b.ReportHealth(false, err.Error())
+			}
+			return
+		}
+		b.ReportHealth(true, "grpc health check passed")
+		return
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, b.URL+c.Path, nil)
+	if err != nil {
+		b.ReportHealth(false, err.Error())
+		return
+	}
+	resp, err := c.Client.Do(req)
+	if err != nil {
+		// Отмена контекста при остановке не говорит о состоянии бэкенда.
+		if ctx.Err() == nil {
+			b.ReportHealth(false, err.Error())
+		}
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode != c.ExpectedStatus {
+		b.ReportHealth(false, fmt.Sprintf("health check: unexpected status %d", resp.StatusCode))
+		return
 	}
+	b.ReportHealth(true, "health check passed")
 }
@@ -0,0 +1,78 @@
+package balancer
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/protobuf/proto"
+)
+
+// GRPCHealthCheckPath — метод стандартного протокола проверки здоровья gRPC.
+const GRPCHealthCheckPath = "/grpc.health.v1.Health/Check"
+
+// maxGRPCHealthResponse ограничивает размер читаемого ответа Check.
+const maxGRPCHealthResponse = 64 << 10
+
+// probeGRPC вызывает grpc.health.v1.Health/Check у бэкенда. Клиент Checker
+// должен говорить по HTTP/2 (h2c или HTTP/2 по TLS). Возвращает nil, если
+// сервис в состоянии SERVING.
+func (c *Checker) probeGRPC(ctx context.Context, b *Backend) error {
+	msg, err := proto.Marshal(&healthpb.HealthCheckRequest{Service: c.GRPCService})
+	if err != nil {
+		return err
+	}
+	// Сообщение gRPC: флаг сжатия, длина (big-endian) и protobuf
+	frame := make([]byte, 5+len(msg))
+	binary.BigEndian.PutUint32(frame[1:5], uint32(len(msg)))
+	copy(frame[5:], msg)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, b.URL+GRPCHealthCheckPath, bytes.NewReader(frame))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/grpc")
+	req.Header.Set("TE", "trailers")
+	resp, err := c.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("grpc health check: unexpected status %d", resp.StatusCode)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxGRPCHealthResponse))
+	if err != nil {
+		return err
+	}
+
+	// Статус приходит в трейлерах, а при ответе без сообщений — в заголовках
+	status := resp.Trailer.Get("Grpc-Status")
+	if status == "" {
+		status = resp.Header.Get("Grpc-Status")
+	}
+	if code, err := strconv.Atoi(status); err != nil || code != 0 {
+		message := resp.Trailer.Get("Grpc-Message")
+		if message == "" {
+			message = resp.Header.Get("Grpc-Message")
+		}
+		return fmt.Errorf("grpc health check: status %q: %s", status, message)
+	}
+
+	if len(body) < 5 || body[0] != 0 || int(binary.BigEndian.Uint32(body[1:5])) != len(body)-5 {
+		return fmt.Errorf("grpc health check: malformed response")
+	}
+	var out healthpb.HealthCheckResponse
+	if err := proto.Unmarshal(body[5:], &out); err != nil {
+		return fmt.Errorf("grpc health check: %w", err)
+	}
+	if out.Status != healthpb.HealthCheckResponse_SERVING {
+		return fmt.Errorf("grpc health check: service is %s", out.Status)
+	}
+	return nil
+}
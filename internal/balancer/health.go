@@ -0,0 +1,87 @@
+package balancer
+
+import (
+	"time"
+)
+
+// DefaultHealthHistorySize — сколько последних результатов проверок хранится на бэкенде.
+const DefaultHealthHistorySize = 10
+
+// HealthPolicy задаёт пороги переключения состояния бэкенда.
+type HealthPolicy struct {
+	FallThreshold int           // число подряд неудачных проверок до пометки мёртвым
+	RiseThreshold int           // число подряд успешных проверок до возврата в пул
+	HoldDown      time.Duration // минимальный интервал между сменами состояния
+	HistorySize   int           // размер истории результатов
+}
+
+// DefaultHealthPolicy возвращает политику, при которой состояние меняется после первого же результата.
+func DefaultHealthPolicy() HealthPolicy {
+	return HealthPolicy{
+		FallThreshold: 1,
+		RiseThreshold: 1,
+		HistorySize:   DefaultHealthHistorySize,
+	}
+}
+
+// normalized подставляет допустимые значения вместо нулевых.
+func (p HealthPolicy) normalized() HealthPolicy {
+	if p.FallThreshold <= 0 {
+		p.FallThreshold = 1
+	}
+	if p.RiseThreshold <= 0 {
+		p.RiseThreshold = 1
+	}
+	if p.HistorySize <= 0 {
+		p.HistorySize = DefaultHealthHistorySize
+	}
+	if p.HoldDown < 0 {
+		p.HoldDown = 0
+	}
+	return p
+}
+
+// HealthResult — результат одной проверки или одного проксированного запроса.
+type HealthResult struct {
+	Time   time.Time `json:"time"`
+	OK     bool      `json:"ok"`
+	Reason string    `json:"reason,omitempty"`
+}
+
+// HealthStatus — снимок состояния здоровья бэкенда.
+type HealthStatus struct {
+	URL                  string         `json:"url"`
+	Alive                bool           `json:"alive"`
+	ConsecutiveSuccesses int            `json:"consecutive_successes"`
+	ConsecutiveFailures  int            `json:"consecutive_failures"`
+	LastTransition       time.Time      `json:"last_transition"`
+	LastReason           string         `json:"last_reason,omitempty"`
+	History              []HealthResult `json:"history"`
+	Ejected              bool           `json:"ejected"`       // исключён детектором выбросов
+	EjectedUntil         time.Time      `json:"ejected_until"` // до какого момента
+	Ejections            int            `json:"ejections"`     // множитель времени исключения
+	Breaker              string         `json:"breaker"`       // состояние выключателя
+}
+
+// healthState хранит историю проверок бэкенда. Защищается мьютексом Backend.
+type healthState struct {
+	policy         HealthPolicy
+	successes      int
+	failures       int
+	lastTransition time.Time
+	lastReason     string
+	history        []HealthResult
+}
+
+// record добавляет результат в историю, обрезая её до размера из политики.
+func (h *healthState) record(r HealthResult) {
+	h.history = append(h.history, r)
+	h.trim()
+}
+
+// trim оставляет в истории только последние HistorySize результатов.
+func (h *healthState) trim() {
+	if extra := len(h.history) - h.policy.HistorySize; extra > 0 {
+		h.history = append(h.history[:0], h.history[extra:]...)
+	}
+}
@@ -0,0 +1,85 @@
+package balancer
+
+import (
+	"testing"
+	"time"
+)
+
+func TestReportHealthThresholds(t *testing.T) {
+	b := NewBackend("http://a")
+	b.SetHealthPolicy(HealthPolicy{FallThreshold: 3, RiseThreshold: 2})
+
+	// Две ошибки подряд не должны выводить бэкенд из пула
+	b.ReportHealth(false, "timeout")
+	b.ReportHealth(false, "timeout")
+	if !b.IsAlive() {
+		t.Fatal("backend marked dead before fall threshold")
+	}
+
+	// Успех сбрасывает счётчик неудач
+	b.ReportHealth(true, "ok")
+	b.ReportHealth(false, "timeout")
+	b.ReportHealth(false, "timeout")
+	if !b.IsAlive() {
+		t.Fatal("failure counter was not reset by success")
+	}
+
+	if !b.ReportHealth(false, "timeout") || b.IsAlive() {
+		t.Fatal("expected backend to be marked dead after 3 consecutive failures")
+	}
+
+	b.ReportHealth(true, "ok")
+	if b.IsAlive() {
+		t.Fatal("backend revived before rise threshold")
+	}
+	b.ReportHealth(true, "ok")
+	if !b.IsAlive() {
+		t.Fatal("expected backend to be alive after 2 consecutive successes")
+	}
+}
+
+func TestReportHealthHoldDown(t *testing.T) {
+	b := NewBackend("http://a")
+	b.SetHealthPolicy(HealthPolicy{FallThreshold: 1, RiseThreshold: 1, HoldDown: 100 * time.Millisecond})
+
+	b.ReportHealth(false, "refused")
+	if b.IsAlive() {
+		t.Fatal("expected backend to be dead")
+	}
+
+	// Внутри окна HoldDown состояние не меняется
+	b.ReportHealth(true, "ok")
+	if b.IsAlive() {
+		t.Fatal("backend flapped inside hold-down window")
+	}
+
+	time.Sleep(120 * time.Millisecond)
+	b.ReportHealth(true, "ok")
+	if !b.IsAlive() {
+		t.Fatal("expected backend to be alive after hold-down expired")
+	}
+}
+
+func TestHealthStatusHistory(t *testing.T) {
+	b := NewBackend("http://a")
+	b.SetHealthPolicy(HealthPolicy{FallThreshold: 2, HistorySize: 3})
+
+	b.ReportHealth(true, "ok")
+	b.ReportHealth(true, "ok")
+	b.ReportHealth(false, "status 500")
+	b.ReportHealth(false, "status 503")
+
+	status := b.HealthStatus()
+	if len(status.History) != 3 {
+		t.Fatalf("expected 3 history entries, got %d", len(status.History))
+	}
+	if status.History[2].Reason != "status 503" {
+		t.Errorf("expected last result to be 'status 503', got %q", status.History[2].Reason)
+	}
+	if status.Alive || status.ConsecutiveFailures != 2 || status.LastReason != "status 503" {
+		t.Errorf("unexpected status: %+v", status)
+	}
+	if status.LastTransition.IsZero() {
+		t.Error("expected last transition time to be recorded")
+	}
+}
@@ -0,0 +1,75 @@
+package balancer
+
+import (
+	"math"
+	"time"
+)
+
+const (
+	// DefaultLatencyDecay — постоянная затухания EWMA задержки по умолчанию.
+	DefaultLatencyDecay = 10 * time.Second
+	// UnsampledLatencyPenalty — оценка задержки бэкенда без замеров, у которого
+	// уже есть запрос в работе. Не даёт завалить новый бэкенд запросами, пока
+	// не пришёл первый ответ.
+	UnsampledLatencyPenalty = time.Second
+)
+
+// latencyState хранит peak-EWMA задержки ответа бэкенда. Защищается мьютексом Backend.
+type latencyState struct {
+	ewma       float64 // в наносекундах
+	lastUpdate time.Time
+	sampled    bool
+}
+
+// observe учитывает новый замер: пики принимаются сразу, а снижение
+// сглаживается с весом, зависящим от времени с прошлого замера.
+func (l *latencyState) observe(rtt time.Duration, decay time.Duration, now time.Time) {
+	sample := float64(rtt)
+	if !l.sampled || sample > l.ewma {
+		l.ewma = sample
+	} else {
+		elapsed := now.Sub(l.lastUpdate)
+		if elapsed < 0 {
+			elapsed = 0
+		}
+		w := math.Exp(-float64(elapsed) / float64(decay))
+		l.ewma = l.ewma*w + sample*(1-w)
+	}
+	l.sampled = true
+	l.lastUpdate = now
+}
+
+// ObserveLatency учитывает время ответа бэкенда в его peak-EWMA.
+// decay — постоянная затухания; 0 означает DefaultLatencyDecay.
+func (b *Backend) ObserveLatency(rtt time.Duration, decay time.Duration) {
+	if decay <= 0 {
+		decay = DefaultLatencyDecay
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.latency.observe(rtt, decay, time.Now())
+}
+
+// LatencyEWMA возвращает текущую оценку задержки и признак наличия замеров.
+func (b *Backend) LatencyEWMA() (time.Duration, bool) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return time.Duration(b.latency.ewma), b.latency.sampled
+}
+
+// LatencyScore возвращает нагрузочную оценку бэкенда: EWMA задержки,
+// умноженную на число запросов в работе плюс один. Бэкенд без замеров
+// получает 0, пока свободен, и UnsampledLatencyPenalty, пока обрабатывает запрос.
+func (b *Backend) LatencyScore() float64 {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	cost := b.latency.ewma
+	if !b.latency.sampled {
+		if b.ActiveConnections == 0 {
+			return 0
+		}
+		cost = float64(UnsampledLatencyPenalty)
+	}
+	return cost * float64(b.ActiveConnections+1)
+}
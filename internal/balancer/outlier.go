@@ -0,0 +1,163 @@
+package balancer
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// OutlierConfig задаёт пассивное обнаружение выбросов по реальному трафику (в духе Envoy).
+type OutlierConfig struct {
+	Consecutive5xx           int           // ответов 5xx подряд до исключения (0 — не учитывать)
+	ConsecutiveGatewayErrors int           // 502/503/504 и транспортных ошибок подряд до исключения (0 — не учитывать)
+	BaseEjectionTime         time.Duration // базовое время исключения, растёт с каждым повтором
+	MaxEjectionTime          time.Duration // верхняя граница времени исключения
+	MaxEjectionPercent       int           // максимальная доля пула, исключённая одновременно
+}
+
+// DefaultOutlierConfig возвращает параметры по умолчанию.
+func DefaultOutlierConfig() OutlierConfig {
+	return OutlierConfig{
+		Consecutive5xx:           5,
+		ConsecutiveGatewayErrors: 5,
+		BaseEjectionTime:         30 * time.Second,
+		MaxEjectionTime:          300 * time.Second,
+		MaxEjectionPercent:       10,
+	}
+}
+
+// outlierState — счётчики ошибок и состояние исключения бэкенда. Защищается мьютексом Backend.
+type outlierState struct {
+	consecutive5xx     int
+	consecutiveGateway int
+	ejectedUntil       time.Time
+	ejections          int // множитель времени исключения
+}
+
+// IsEjected сообщает, исключён ли бэкенд детектором выбросов в данный момент.
+func (b *Backend) IsEjected() bool {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return time.Now().Before(b.outlier.ejectedUntil)
+}
+
+// IsAvailable сообщает, можно ли отправлять на бэкенд запросы: он жив по
+// health checks, не исключён детектором выбросов и его выключатель не разомкнут.
+func (b *Backend) IsAvailable() bool {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	if !b.Alive || time.Now().Before(b.outlier.ejectedUntil) {
+		return false
+	}
+	return b.breaker == nil || b.breaker.Ready()
+}
+
+// OutlierDetector считает подряд идущие ошибки бэкендов и временно исключает
+// выбросы из выбора. Время исключения — BaseEjectionTime, умноженное на число
+// исключений подряд, но не больше MaxEjectionTime. Одновременно исключается не
+// больше MaxEjectionPercent пула (но хотя бы один бэкенд) и никогда — весь пул.
+type OutlierDetector struct {
+	cfg OutlierConfig
+	mu  sync.Mutex // сериализует решения об исключении, чтобы соблюдался лимит
+}
+
+// NewOutlierDetector создаёт детектор. Незаданные времена берутся из DefaultOutlierConfig.
+func NewOutlierDetector(cfg OutlierConfig) *OutlierDetector {
+	def := DefaultOutlierConfig()
+	if cfg.BaseEjectionTime <= 0 {
+		cfg.BaseEjectionTime = def.BaseEjectionTime
+	}
+	if cfg.MaxEjectionTime < cfg.BaseEjectionTime {
+		cfg.MaxEjectionTime = cfg.BaseEjectionTime
+	}
+	if cfg.MaxEjectionPercent < 0 {
+		cfg.MaxEjectionPercent = 0
+	}
+	return &OutlierDetector{cfg: cfg}
+}
+
+// Observe учитывает результат запроса к бэкенду. Если бэкенд исключён,
+// возвращает время исключения и причину.
+func (d *OutlierDetector) Observe(p *ServerPool, b *Backend, outcome Outcome) (time.Duration, string, bool) {
+	reason, trip := d.count(b, outcome)
+	if !trip {
+		return 0, "", false
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if !d.canEject(p, b) {
+		return 0, "", false
+	}
+	return d.eject(b), reason, true
+}
+
+// count обновляет счётчики бэкенда и сообщает, достигнут ли порог.
+func (d *OutlierDetector) count(b *Backend, outcome Outcome) (string, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	o := &b.outlier
+	switch outcome {
+	case OutcomeSuccess:
+		o.consecutive5xx = 0
+		o.consecutiveGateway = 0
+		return "", false
+	case OutcomeFailure:
+		o.consecutive5xx++
+		o.consecutiveGateway = 0
+	case OutcomeGatewayFailure, OutcomeError:
+		o.consecutive5xx++
+		o.consecutiveGateway++
+	}
+
+	switch {
+	case d.cfg.ConsecutiveGatewayErrors > 0 && o.consecutiveGateway >= d.cfg.ConsecutiveGatewayErrors:
+		return fmt.Sprintf("%d consecutive gateway errors", o.consecutiveGateway), true
+	case d.cfg.Consecutive5xx > 0 && o.consecutive5xx >= d.cfg.Consecutive5xx:
+		return fmt.Sprintf("%d consecutive 5xx", o.consecutive5xx), true
+	}
+	return "", false
+}
+
+// canEject проверяет лимит одновременно исключённых бэкендов.
+func (d *OutlierDetector) canEject(p *ServerPool, b *Backend) bool {
+	backends := p.AllBackends()
+	ejected := 0
+	for _, other := range backends {
+		if other == b {
+			continue
+		}
+		if other.IsEjected() {
+			ejected++
+		}
+	}
+	if b.IsEjected() || ejected+1 >= len(backends) {
+		return false // уже исключён или это исключило бы весь пул
+	}
+	return ejected == 0 || (ejected+1)*100 <= d.cfg.MaxEjectionPercent*len(backends)
+}
+
+// eject исключает бэкенд и возвращает время исключения.
+func (d *OutlierDetector) eject(b *Backend) time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now()
+	o := &b.outlier
+	// Бэкенд, долго работавший без исключений, начинает с базового времени
+	if !o.ejectedUntil.IsZero() && now.Sub(o.ejectedUntil) > d.cfg.MaxEjectionTime {
+		o.ejections = 0
+	}
+	o.ejections++
+
+	ejection := d.cfg.BaseEjectionTime * time.Duration(o.ejections)
+	if ejection > d.cfg.MaxEjectionTime {
+		ejection = d.cfg.MaxEjectionTime
+	}
+	o.ejectedUntil = now.Add(ejection)
+	o.consecutive5xx = 0
+	o.consecutiveGateway = 0
+	return ejection
+}
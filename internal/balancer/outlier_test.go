@@ -0,0 +1,110 @@
+package balancer
+
+import (
+	"testing"
+	"time"
+)
+
+func newOutlierPool(n int, cfg OutlierConfig) *ServerPool {
+	urls := []string{"http://a", "http://b", "http://c", "http://d"}[:n]
+	pool := NewServerPool(urls)
+	pool.SetStrategy(NewRoundRobinStrategy())
+	pool.SetOutlierDetector(NewOutlierDetector(cfg))
+	return pool
+}
+
+func TestOutlierDetector_ConsecutiveGatewayErrors(t *testing.T) {
+	pool := newOutlierPool(3, OutlierConfig{ConsecutiveGatewayErrors: 3, BaseEjectionTime: time.Minute, MaxEjectionPercent: 50})
+	b := pool.AllBackends()[0]
+
+	pool.Done(b, OutcomeError, 0)
+	pool.Done(b, OutcomeGatewayFailure, 0)
+	if b.IsEjected() {
+		t.Fatal("backend ejected before threshold")
+	}
+
+	// Успешный ответ сбрасывает счётчик
+	pool.Done(b, OutcomeSuccess, time.Millisecond)
+	pool.Done(b, OutcomeError, 0)
+	pool.Done(b, OutcomeError, 0)
+	if b.IsEjected() {
+		t.Fatal("success did not reset consecutive errors")
+	}
+
+	pool.Done(b, OutcomeError, 0)
+	if !b.IsEjected() || b.IsAvailable() {
+		t.Fatal("expected backend to be ejected after 3 consecutive gateway errors")
+	}
+	if !b.IsAlive() {
+		t.Error("ejection must not change health check status")
+	}
+
+	for i := 0; i < 6; i++ {
+		if pool.NextBackend() == b {
+			t.Fatal("ejected backend was selected")
+		}
+	}
+}
+
+func TestOutlierDetector_Consecutive5xx(t *testing.T) {
+	pool := newOutlierPool(2, OutlierConfig{Consecutive5xx: 2, MaxEjectionPercent: 50})
+	b := pool.AllBackends()[1]
+
+	pool.Done(b, OutcomeFailure, time.Millisecond)
+	pool.Done(b, OutcomeGatewayFailure, time.Millisecond)
+	if !b.IsEjected() {
+		t.Fatal("expected backend to be ejected after 2 consecutive 5xx")
+	}
+}
+
+func TestOutlierDetector_EjectionTimeGrows(t *testing.T) {
+	d := NewOutlierDetector(OutlierConfig{
+		ConsecutiveGatewayErrors: 1,
+		BaseEjectionTime:         20 * time.Millisecond,
+		MaxEjectionTime:          50 * time.Millisecond,
+		MaxEjectionPercent:       50,
+	})
+	pool := NewServerPool([]string{"http://a", "http://b"})
+	b := pool.AllBackends()[0]
+
+	want := []time.Duration{20 * time.Millisecond, 40 * time.Millisecond, 50 * time.Millisecond}
+	for i, expected := range want {
+		ejection, _, ok := d.Observe(pool, b, OutcomeError)
+		if !ok || ejection != expected {
+			t.Fatalf("ejection %d: got %v (ejected=%v), want %v", i+1, ejection, ok, expected)
+		}
+		time.Sleep(ejection + 5*time.Millisecond)
+		if !b.IsAvailable() {
+			t.Fatalf("expected backend to return after ejection %d", i+1)
+		}
+	}
+}
+
+func TestOutlierDetector_MaxEjectionPercent(t *testing.T) {
+	pool := newOutlierPool(4, OutlierConfig{ConsecutiveGatewayErrors: 1, BaseEjectionTime: time.Minute, MaxEjectionPercent: 25})
+	backends := pool.AllBackends()
+
+	for _, b := range backends {
+		pool.Done(b, OutcomeError, 0)
+	}
+
+	ejected := 0
+	for _, b := range backends {
+		if b.IsEjected() {
+			ejected++
+		}
+	}
+	if ejected != 1 {
+		t.Fatalf("expected exactly 1 of 4 backends ejected with 25%% cap, got %d", ejected)
+	}
+}
+
+func TestOutlierDetector_NeverEjectsWholePool(t *testing.T) {
+	pool := newOutlierPool(1, OutlierConfig{ConsecutiveGatewayErrors: 1, MaxEjectionPercent: 100})
+	b := pool.AllBackends()[0]
+
+	pool.Done(b, OutcomeError, 0)
+	if b.IsEjected() {
+		t.Fatal("the only backend in the pool must not be ejected")
+	}
+}
@@ -2,13 +2,22 @@ package balancer
 
 import (
 	"sync"
+	"time"
+
+	"go.uber.org/zap"
 )
 
 // ServerPool управляет всеми бэкендами и стратегией выбора.
 type ServerPool struct {
 	backends []*Backend
 	current  uint64       // для round-robin
-	strategy Strategy
+	strategy SelectionStrategy
+	health   HealthPolicy  // политика порогов для всех бэкендов пула
+	decay    time.Duration // постоянная затухания EWMA задержки
+	outliers *OutlierDetector // пассивное обнаружение выбросов (nil — выключено)
+	breakers *BreakerConfig   // параметры выключателей бэкендов (nil — выключены)
+	retries  *RetryBudget     // бюджет повторов запросов (nil — без ограничений)
+	logger   *zap.SugaredLogger
 	mu       sync.RWMutex
 }
 
@@ -18,27 +27,138 @@ func NewServerPool(urls []string) *ServerPool {
 	for _, url := range urls {
 		backends = append(backends, NewBackend(url))
 	}
+	return NewServerPoolFromBackends(backends)
+}
+
+// NewServerPoolFromBackends создаёт пул из заранее созданных бэкендов (например, с весами).
+func NewServerPoolFromBackends(backends []*Backend) *ServerPool {
 	return &ServerPool{
 		backends: backends,
+		health:   DefaultHealthPolicy(),
+		decay:    DefaultLatencyDecay,
+		logger:   zap.NewNop().Sugar(),
+	}
+}
+
+// SetLogger задаёт логгер для событий пула (исключение бэкендов и т.п.).
+func (p *ServerPool) SetLogger(logger *zap.SugaredLogger) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.logger = logger
+}
+
+// SetCircuitBreakers подключает выключатель к каждому бэкенду пула, включая
+// добавленные позже. Смены состояния выключателей пишутся в лог пула.
+func (p *ServerPool) SetCircuitBreakers(cfg BreakerConfig) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	cfg = cfg.normalized()
+	p.breakers = &cfg
+	for _, b := range p.backends {
+		b.SetCircuitBreaker(p.newBreaker(b))
+	}
+}
+
+// newBreaker создаёт выключатель для бэкенда. Вызывается под p.mu.
+func (p *ServerPool) newBreaker(b *Backend) *CircuitBreaker {
+	cb := NewCircuitBreaker(*p.breakers)
+	cb.OnStateChange = func(from, to BreakerState) {
+		p.mu.RLock()
+		logger := p.logger
+		p.mu.RUnlock()
+		logger.Warnw("circuit breaker state changed", "backend", b.URL, "from", from.String(), "to", to.String())
+	}
+	return cb
+}
+
+// SetRetryBudget ограничивает долю повторов запросов к пулу.
+func (p *ServerPool) SetRetryBudget(rb *RetryBudget) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.retries = rb
+}
+
+// RecordRequest учитывает исходный запрос в бюджете повторов пула.
+func (p *ServerPool) RecordRequest() {
+	p.mu.RLock()
+	rb := p.retries
+	p.mu.RUnlock()
+	if rb != nil {
+		rb.RecordRequest()
+	}
+}
+
+// TryRetry расходует повтор из бюджета пула. Без бюджета повторы не ограничены.
+func (p *ServerPool) TryRetry() bool {
+	p.mu.RLock()
+	rb := p.retries
+	p.mu.RUnlock()
+	return rb == nil || rb.TryRetry()
+}
+
+// SetOutlierDetector включает пассивное обнаружение выбросов по результатам запросов.
+func (p *ServerPool) SetOutlierDetector(d *OutlierDetector) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.outliers = d
+}
+
+// SetLatencyDecay задаёт постоянную затухания EWMA задержки бэкендов.
+func (p *ServerPool) SetLatencyDecay(decay time.Duration) {
+	if decay <= 0 {
+		decay = DefaultLatencyDecay
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.decay = decay
+}
+
+// SetHealthPolicy задаёт пороги переключения состояния для всех бэкендов пула,
+// включая добавленные позже.
+func (p *ServerPool) SetHealthPolicy(policy HealthPolicy) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.health = policy.normalized()
+	for _, b := range p.backends {
+		b.SetHealthPolicy(p.health)
 	}
 }
 
-// SetStrategy задаёт стратегию выбора бэкенда.
+// SetStrategy задаёт стратегию выбора бэкенда. Стратегии со старым
+// интерфейсом оборачиваются через AdaptStrategy.
 func (p *ServerPool) SetStrategy(s Strategy) {
+	p.SetSelectionStrategy(AdaptStrategy(s))
+}
+
+// SetSelectionStrategy задаёт стратегию выбора бэкенда с доступом к запросу.
+func (p *ServerPool) SetSelectionStrategy(s SelectionStrategy) {
 	p.mu.Lock()
 	defer p.mu.Unlock()
 	p.strategy = s
 }
 
 // GetStrategy возвращает текущую стратегию.
-func (p *ServerPool) GetStrategy() Strategy {
+func (p *ServerPool) GetStrategy() SelectionStrategy {
 	p.mu.RLock()
 	defer p.mu.RUnlock()
 	return p.strategy
 }
 
-// NextBackend возвращает следующий бэкенд согласно стратегии.
+// NextBackend возвращает следующий бэкенд согласно стратегии без контекста запроса.
 func (p *ServerPool) NextBackend() *Backend {
+	return p.Select(&SelectionContext{})
+}
+
+// selectAttempts — сколько раз повторить выбор стратегией, если она вернула
+// исключённый для запроса бэкенд или выключатель бэкенда исчерпал пробные запросы.
+const selectAttempts = 3
+
+// Select возвращает бэкенд для запроса, описанного sc, согласно стратегии.
+// Бэкенды, исключённые через sc.Exclude, не выбираются: если стратегия
+// упорно возвращает их, берётся первый доступный из остальных.
+// Выбранный бэкенд должен получить запрос, а его результат — вернуться в Done:
+// в полуоткрытом состоянии выключателя это расходует пробный запрос.
+func (p *ServerPool) Select(sc *SelectionContext) *Backend {
 	p.mu.RLock()
 	strategy := p.strategy
 	p.mu.RUnlock()
@@ -46,17 +166,60 @@ func (p *ServerPool) NextBackend() *Backend {
 	if strategy == nil {
 		return nil
 	}
-	return strategy.Next(p)
+	if sc == nil {
+		sc = &SelectionContext{}
+	}
+	for attempt := 0; attempt < selectAttempts; attempt++ {
+		b := strategy.Select(p, sc)
+		if b == nil {
+			return nil
+		}
+		if !sc.IsExcluded(b) && b.AllowRequest() {
+			return b
+		}
+	}
+	for _, b := range p.GetAliveBackends() {
+		if !sc.IsExcluded(b) && b.AllowRequest() {
+			return b
+		}
+	}
+	return nil
 }
 
-// GetAliveBackends возвращает список живых бэкендов.
+// Done учитывает задержку ответа в EWMA бэкенда, передаёт результат выключателю
+// и детектору выбросов и сообщает его стратегии, если она его учитывает. Задержка транспортных
+// ошибок не учитывается: быстрый отказ в соединении не делает бэкенд "быстрым".
+// Отменённый запрос только возвращает пробный запрос выключателю: ни задержки,
+// ни результата у него нет.
+func (p *ServerPool) Done(b *Backend, outcome Outcome, latency time.Duration) {
+	p.mu.RLock()
+	strategy, decay, outliers, logger := p.strategy, p.decay, p.outliers, p.logger
+	p.mu.RUnlock()
+
+	if outcome != OutcomeError && outcome != OutcomeCancelled {
+		b.ObserveLatency(latency, decay)
+	}
+	b.recordOutcome(outcome)
+	if outliers != nil && outcome != OutcomeCancelled {
+		if ejection, reason, ok := outliers.Observe(p, b, outcome); ok {
+			logger.Warnw("backend ejected as outlier", "backend", b.URL, "reason", reason, "ejection", ejection)
+		}
+	}
+
+	if o, ok := strategy.(StrategyObserver); ok {
+		o.Done(b, outcome, latency)
+	}
+}
+
+// GetAliveBackends возвращает список бэкендов, доступных для выбора:
+// живых и не исключённых детектором выбросов.
 func (p *ServerPool) GetAliveBackends() []*Backend {
 	p.mu.RLock()
 	defer p.mu.RUnlock()
 
 	alive := make([]*Backend, 0)
 	for _, b := range p.backends {
-		if b.IsAlive() {
+		if b.IsAvailable() {
 			alive = append(alive, b)
 		}
 	}
@@ -74,7 +237,12 @@ func (p *ServerPool) AllBackends() []*Backend {
 func (p *ServerPool) AddBackend(url string) {
 	p.mu.Lock()
 	defer p.mu.Unlock()
-	p.backends = append(p.backends, NewBackend(url))
+	b := NewBackend(url)
+	b.SetHealthPolicy(p.health)
+	if p.breakers != nil {
+		b.SetCircuitBreaker(p.newBreaker(b))
+	}
+	p.backends = append(p.backends, b)
 }
 
 // MarkBackendAlive обновляет статус живости бэкенда по URL.
@@ -90,6 +258,30 @@ func (p *ServerPool) MarkBackendAlive(url string, alive bool) {
 	}
 }
 
+// ReportBackendHealth передаёт результат проверки бэкенду по URL с учётом порогов HealthPolicy.
+func (p *ServerPool) ReportBackendHealth(url string, ok bool, reason string) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	for _, b := range p.backends {
+		if b.URL == url {
+			b.ReportHealth(ok, reason)
+			break
+		}
+	}
+}
+
+// HealthStatuses возвращает снимки состояния здоровья всех бэкендов пула.
+func (p *ServerPool) HealthStatuses() []HealthStatus {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	statuses := make([]HealthStatus, 0, len(p.backends))
+	for _, b := range p.backends {
+		statuses = append(statuses, b.HealthStatus())
+	}
+	return statuses
+}
 
 // ResetConnections сбрасывает количество соединений у всех бэкендов.
 func (p *ServerPool) ResetConnections() {
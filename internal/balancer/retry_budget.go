@@ -0,0 +1,58 @@
+package balancer
+
+import (
+	"sync"
+	"time"
+)
+
+// RetryBudget ограничивает долю повторов в общем потоке запросов пула, чтобы
+// повторы не умножали нагрузку во время аварии. За скользящее окно допускается
+// MinPerSecond*окно повторов плюс Percent процентов от числа запросов.
+type RetryBudget struct {
+	percent      float64
+	minPerSecond float64
+	window       time.Duration
+
+	mu       sync.Mutex
+	requests rollingWindow
+	retries  rollingWindow
+}
+
+// NewRetryBudget создаёт бюджет повторов. window <= 0 означает 10 секунд.
+func NewRetryBudget(percent float64, minPerSecond float64, window time.Duration) *RetryBudget {
+	if window <= 0 {
+		window = 10 * time.Second
+	}
+	const buckets = 10
+	return &RetryBudget{
+		percent:      percent,
+		minPerSecond: minPerSecond,
+		window:       window,
+		requests:     newRollingWindow(window, buckets),
+		retries:      newRollingWindow(window, buckets),
+	}
+}
+
+// RecordRequest учитывает исходный (не повторный) запрос.
+func (rb *RetryBudget) RecordRequest() {
+	rb.mu.Lock()
+	defer rb.mu.Unlock()
+	rb.requests.add(time.Now(), false)
+}
+
+// TryRetry расходует один повтор из бюджета. Возвращает false, если бюджет исчерпан.
+func (rb *RetryBudget) TryRetry() bool {
+	now := time.Now()
+
+	rb.mu.Lock()
+	defer rb.mu.Unlock()
+
+	requests, _ := rb.requests.counts(now)
+	retries, _ := rb.retries.counts(now)
+	allowed := rb.minPerSecond*rb.window.Seconds() + rb.percent/100*float64(requests)
+	if float64(retries+1) > allowed {
+		return false
+	}
+	rb.retries.add(now, false)
+	return true
+}
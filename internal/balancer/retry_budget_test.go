@@ -0,0 +1,50 @@
+package balancer
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRetryBudget_PercentOfRequests(t *testing.T) {
+	rb := NewRetryBudget(20, 0, time.Minute)
+
+	for i := 0; i < 10; i++ {
+		rb.RecordRequest()
+	}
+
+	// 20% от 10 запросов — два повтора
+	if !rb.TryRetry() || !rb.TryRetry() {
+		t.Fatal("expected 2 retries to fit into the budget")
+	}
+	if rb.TryRetry() {
+		t.Fatal("expected budget to be exhausted after 2 retries")
+	}
+
+	for i := 0; i < 5; i++ {
+		rb.RecordRequest()
+	}
+	if !rb.TryRetry() {
+		t.Error("expected new requests to replenish the budget")
+	}
+}
+
+func TestRetryBudget_MinPerSecond(t *testing.T) {
+	rb := NewRetryBudget(0, 1, 2*time.Second)
+
+	// Без запросов доступна минимальная квота: 1 в секунду за окно 2 секунды
+	if !rb.TryRetry() || !rb.TryRetry() {
+		t.Fatal("expected minimum allowance of 2 retries")
+	}
+	if rb.TryRetry() {
+		t.Fatal("expected minimum allowance to be exhausted")
+	}
+}
+
+func TestPoolWithoutRetryBudget(t *testing.T) {
+	pool := NewServerPool([]string{"http://a"})
+	for i := 0; i < 100; i++ {
+		if !pool.TryRetry() {
+			t.Fatal("pool without budget must not limit retries")
+		}
+	}
+}
@@ -0,0 +1,139 @@
+package balancer
+
+import (
+	"net"
+	"net/http"
+	"time"
+)
+
+// SelectionContext — данные о запросе, доступные стратегии при выборе бэкенда.
+type SelectionContext struct {
+	Request  *http.Request // исходный запрос; nil, если выбор идёт вне запроса
+	ClientIP string        // IP клиента, вычисленный прокси
+	excluded []*Backend    // бэкенды, на которых этот запрос уже завершился ошибкой
+}
+
+// Exclude запрещает повторно выбирать бэкенд для этого запроса.
+func (sc *SelectionContext) Exclude(b *Backend) {
+	if !sc.IsExcluded(b) {
+		sc.excluded = append(sc.excluded, b)
+	}
+}
+
+// IsExcluded сообщает, исключён ли бэкенд для этого запроса.
+func (sc *SelectionContext) IsExcluded(b *Backend) bool {
+	for _, e := range sc.excluded {
+		if e == b {
+			return true
+		}
+	}
+	return false
+}
+
+// Clone возвращает копию контекста со своим списком исключённых бэкендов.
+func (sc *SelectionContext) Clone() *SelectionContext {
+	c := *sc
+	c.excluded = append([]*Backend(nil), sc.excluded...)
+	return &c
+}
+
+// NewSelectionContext создаёт контекст выбора для запроса.
+func NewSelectionContext(r *http.Request, clientIP string) *SelectionContext {
+	return &SelectionContext{Request: r, ClientIP: clientIP}
+}
+
+// clientIP возвращает IP клиента, а при его отсутствии — адрес соединения.
+func (sc *SelectionContext) clientIP() string {
+	if sc.ClientIP != "" || sc.Request == nil {
+		return sc.ClientIP
+	}
+	if host, _, err := net.SplitHostPort(sc.Request.RemoteAddr); err == nil {
+		return host
+	}
+	return sc.Request.RemoteAddr
+}
+
+// SelectionStrategy — интерфейс стратегий, которым для выбора нужен запрос
+// (заголовки, путь, IP клиента). Стратегии со старым интерфейсом Strategy
+// подключаются через AdaptStrategy.
+type SelectionStrategy interface {
+	// Select выбирает бэкенд для запроса; sc никогда не равен nil
+	Select(*ServerPool, *SelectionContext) *Backend
+}
+
+// Outcome — результат проксирования запроса на выбранный бэкенд.
+type Outcome int
+
+const (
+	OutcomeSuccess        Outcome = iota // бэкенд ответил без ошибки сервера
+	OutcomeFailure                       // бэкенд ответил статусом 5xx, кроме 502/503/504
+	OutcomeError                         // транспортная ошибка: отказ в соединении, таймаут, разрыв
+	OutcomeGatewayFailure                // бэкенд ответил 502, 503 или 504
+	OutcomeCancelled                     // запрос отменён до ответа: проиграл хеджирование или клиент ушёл
+)
+
+// OutcomeFromStatus возвращает результат запроса по коду ответа бэкенда.
+func OutcomeFromStatus(status int) Outcome {
+	switch {
+	case status == http.StatusBadGateway || status == http.StatusServiceUnavailable || status == http.StatusGatewayTimeout:
+		return OutcomeGatewayFailure
+	case status >= http.StatusInternalServerError:
+		return OutcomeFailure
+	default:
+		return OutcomeSuccess
+	}
+}
+
+// String возвращает имя результата для логов.
+func (o Outcome) String() string {
+	switch o {
+	case OutcomeSuccess:
+		return "success"
+	case OutcomeFailure:
+		return "failure"
+	case OutcomeError:
+		return "error"
+	case OutcomeGatewayFailure:
+		return "gateway_failure"
+	case OutcomeCancelled:
+		return "cancelled"
+	default:
+		return "unknown"
+	}
+}
+
+// StrategyObserver — необязательное расширение стратегии: получает результат
+// каждого запроса, чтобы учитывать его при следующих выборах.
+type StrategyObserver interface {
+	// Done вызывается после завершения запроса к бэкенду
+	Done(b *Backend, outcome Outcome, latency time.Duration)
+}
+
+// legacyStrategy — совместимость со стратегиями, реализующими только Next.
+type legacyStrategy struct {
+	Strategy
+}
+
+// Select игнорирует контекст запроса и вызывает Next.
+func (l legacyStrategy) Select(p *ServerPool, _ *SelectionContext) *Backend {
+	return l.Next(p)
+}
+
+// Done передаёт результат исходной стратегии, если она его принимает.
+func (l legacyStrategy) Done(b *Backend, outcome Outcome, latency time.Duration) {
+	if o, ok := l.Strategy.(StrategyObserver); ok {
+		o.Done(b, outcome, latency)
+	}
+}
+
+// AdaptStrategy приводит стратегию к SelectionStrategy. Стратегии, уже
+// реализующие Select, возвращаются как есть.
+func AdaptStrategy(s Strategy) SelectionStrategy {
+	if s == nil {
+		return nil
+	}
+	if ss, ok := s.(SelectionStrategy); ok {
+		return ss
+	}
+	return legacyStrategy{s}
+}
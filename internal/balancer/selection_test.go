@@ -0,0 +1,90 @@
+package balancer
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// headerStrategy выбирает бэкенд по заголовку X-Backend и запоминает результаты.
+type headerStrategy struct {
+	outcomes []Outcome
+}
+
+func (s *headerStrategy) Select(p *ServerPool, sc *SelectionContext) *Backend {
+	if sc.Request == nil {
+		return nil
+	}
+	for _, b := range p.GetAliveBackends() {
+		if b.URL == sc.Request.Header.Get("X-Backend") {
+			return b
+		}
+	}
+	return nil
+}
+
+func (s *headerStrategy) Done(_ *Backend, outcome Outcome, _ time.Duration) {
+	s.outcomes = append(s.outcomes, outcome)
+}
+
+func TestSelectionStrategyReceivesRequest(t *testing.T) {
+	pool := NewServerPool([]string{"http://a", "http://b"})
+	strategy := &headerStrategy{}
+	pool.SetSelectionStrategy(strategy)
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("X-Backend", "http://b")
+
+	backend := pool.Select(NewSelectionContext(req, "10.0.0.1"))
+	if backend == nil || backend.URL != "http://b" {
+		t.Fatalf("expected http://b, got %v", backend)
+	}
+
+	pool.Done(backend, OutcomeFailure, 10*time.Millisecond)
+	if len(strategy.outcomes) != 1 || strategy.outcomes[0] != OutcomeFailure {
+		t.Errorf("expected Done to receive failure outcome, got %v", strategy.outcomes)
+	}
+}
+
+func TestAdaptStrategyLegacy(t *testing.T) {
+	pool := NewServerPool([]string{"http://a", "http://b"})
+	pool.SetStrategy(NewRoundRobinStrategy())
+
+	if _, ok := pool.GetStrategy().(legacyStrategy); !ok {
+		t.Fatalf("expected round robin to be wrapped in compatibility shim, got %T", pool.GetStrategy())
+	}
+
+	// Старые стратегии работают и через Select, и через NextBackend
+	first := pool.Select(NewSelectionContext(httptest.NewRequest("GET", "/", nil), ""))
+	second := pool.NextBackend()
+	if first == nil || second == nil || first == second {
+		t.Errorf("expected round robin to alternate backends, got %v and %v", first, second)
+	}
+
+	// Done для стратегий без обратной связи ничего не делает
+	pool.Done(first, OutcomeSuccess, time.Millisecond)
+}
+
+func TestAdaptStrategyKeepsSelectionStrategy(t *testing.T) {
+	s := NewConsistentHashStrategy(HashKey{Source: HashKeyPath}, 0)
+	if AdaptStrategy(s) != s.(SelectionStrategy) {
+		t.Error("expected strategy implementing Select to be used as is")
+	}
+}
+
+func TestSelectionContextCloneKeepsExclusionsSeparate(t *testing.T) {
+	pool := NewServerPool([]string{"http://a", "http://b"})
+	a, b := pool.AllBackends()[0], pool.AllBackends()[1]
+
+	sc := NewSelectionContext(nil, "")
+	sc.Exclude(a)
+	clone := sc.Clone()
+	clone.Exclude(b)
+
+	if !clone.IsExcluded(a) || !clone.IsExcluded(b) {
+		t.Fatal("expected clone to keep exclusions and add its own")
+	}
+	if sc.IsExcluded(b) {
+		t.Fatal("expected exclusion in clone not to affect the original")
+	}
+}
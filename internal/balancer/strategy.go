@@ -6,18 +6,38 @@ import (
 
 // Strategy определяет интерфейс для всех стратегий балансировки нагрузки.
 // Каждая стратегия должна реализовывать метод Next для выбора следующего бэкенда.
+// Стратегии, которым нужен запрос, дополнительно реализуют SelectionStrategy.
 type Strategy interface {
 	// Next выбирает следующий бэкенд из пула серверов
 	Next(*ServerPool) *Backend
 }
 
+// StrategyOptions — дополнительные параметры стратегий.
+// Нулевые значения означают значения по умолчанию.
+type StrategyOptions struct {
+	HashKey      string // источник ключа для consistent_hash, см. ParseHashKey
+	VirtualNodes int    // виртуальных узлов на единицу веса для consistent_hash
+}
+
 // StrategyFactory создает и возвращает стратегию балансировки нагрузки
 // на основе переданного имени. Поддерживаемые стратегии:
 //   - "round_robin" - циклический перебор бэкендов
 //   - "least_connections" - выбор бэкенда с наименьшим количеством соединений
+//   - "random" - случайный выбор бэкенда
+//   - "weighted_round_robin" - плавный взвешенный round-robin (как в nginx)
+//   - "weighted_least_connections" - наименьшее отношение соединений к весу
+//   - "weighted_random" - случайный выбор с вероятностью, пропорциональной весу
+//   - "consistent_hash" - кольцо хешей по ключу запроса (IP клиента по умолчанию)
+//   - "peak_ewma" (или "least_latency") - наименьшая EWMA задержки с учётом запросов в работе
+//   - "p2c" - менее загруженный из двух случайных бэкендов
 //
 // Возвращает ошибку, если переданное имя стратегии неизвестно.
 func StrategyFactory(strategyName string) (Strategy, error) {
+	return NewStrategy(strategyName, StrategyOptions{})
+}
+
+// NewStrategy работает как StrategyFactory, но принимает параметры стратегии.
+func NewStrategy(strategyName string, opts StrategyOptions) (Strategy, error) {
 	switch strategyName {
 	case "round_robin":
 		return NewRoundRobinStrategy(), nil
@@ -25,6 +45,22 @@ func StrategyFactory(strategyName string) (Strategy, error) {
 		return NewLeastConnectionsStrategy(), nil
 	case "random":
 		return NewRandomStrategy(), nil
+	case "weighted_round_robin":
+		return NewWeightedRoundRobinStrategy(), nil
+	case "weighted_least_connections":
+		return NewWeightedLeastConnectionsStrategy(), nil
+	case "weighted_random":
+		return NewWeightedRandomStrategy(), nil
+	case "consistent_hash":
+		key, err := ParseHashKey(opts.HashKey)
+		if err != nil {
+			return nil, err
+		}
+		return NewConsistentHashStrategy(key, opts.VirtualNodes), nil
+	case "peak_ewma", "least_latency":
+		return NewPeakEWMAStrategy(), nil
+	case "p2c":
+		return NewP2CStrategy(), nil
 	default:
 		return nil, fmt.Errorf("unknown load balancing strategy: %s", strategyName)
 	}
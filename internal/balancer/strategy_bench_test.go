@@ -0,0 +1,59 @@
+package balancer
+
+import (
+	"fmt"
+	"sync/atomic"
+	"testing"
+)
+
+// benchmarkStrategy измеряет стоимость выбора бэкенда для пулов разного размера.
+func benchmarkStrategy(b *testing.B, newStrategy func() Strategy) {
+	for _, size := range []int{3, 10, 100, 1000} {
+		b.Run(fmt.Sprintf("backends=%d", size), func(b *testing.B) {
+			urls := make([]string, size)
+			for i := range urls {
+				urls[i] = fmt.Sprintf("http://backend-%d", i)
+			}
+			pool := NewServerPool(urls)
+			for i, backend := range pool.AllBackends() {
+				backend.ActiveConnections = i % 7
+			}
+			pool.SetStrategy(newStrategy())
+
+			// FailNow нельзя вызывать из горутин RunParallel: промах только отмечается
+			var misses atomic.Int64
+			b.ReportAllocs()
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					if pool.NextBackend() == nil {
+						misses.Add(1)
+					}
+				}
+			})
+			if n := misses.Load(); n > 0 {
+				b.Fatalf("expected backend, got nil %d times", n)
+			}
+		})
+	}
+}
+
+func BenchmarkRoundRobin(b *testing.B) {
+	benchmarkStrategy(b, NewRoundRobinStrategy)
+}
+
+func BenchmarkLeastConnections(b *testing.B) {
+	benchmarkStrategy(b, NewLeastConnectionsStrategy)
+}
+
+func BenchmarkRandom(b *testing.B) {
+	benchmarkStrategy(b, NewRandomStrategy)
+}
+
+func BenchmarkPeakEWMA(b *testing.B) {
+	benchmarkStrategy(b, NewPeakEWMAStrategy)
+}
+
+func BenchmarkP2C(b *testing.B) {
+	benchmarkStrategy(b, NewP2CStrategy)
+}
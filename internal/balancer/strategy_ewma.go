@@ -0,0 +1,29 @@
+package balancer
+
+// PeakEWMAStrategy выбирает бэкенд с наименьшей оценкой
+// "EWMA задержки × (запросов в работе + 1)". Задержки измеряет прокси
+// и передаёт их пулу через ServerPool.Done.
+type PeakEWMAStrategy struct{}
+
+func NewPeakEWMAStrategy() Strategy {
+	return &PeakEWMAStrategy{}
+}
+
+// Next выбирает живой бэкенд с минимальной LatencyScore.
+func (s *PeakEWMAStrategy) Next(p *ServerPool) *Backend {
+	alive := p.GetAliveBackends()
+	if len(alive) == 0 {
+		return nil
+	}
+
+	var best *Backend
+	var bestScore float64
+	for _, b := range alive {
+		score := b.LatencyScore()
+		if best == nil || score < bestScore {
+			best = b
+			bestScore = score
+		}
+	}
+	return best
+}
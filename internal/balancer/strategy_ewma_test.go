@@ -0,0 +1,71 @@
+package balancer
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPeakEWMAStrategy(t *testing.T) {
+	pool := NewServerPool([]string{"http://slow", "http://fast"})
+	slow, fast := pool.AllBackends()[0], pool.AllBackends()[1]
+	pool.SetStrategy(NewPeakEWMAStrategy())
+
+	pool.Done(slow, OutcomeSuccess, 200*time.Millisecond)
+	pool.Done(fast, OutcomeSuccess, 20*time.Millisecond)
+
+	if backend := pool.NextBackend(); backend != fast {
+		t.Fatalf("expected fast backend, got %v", backend)
+	}
+
+	// Нагрузка на быстрый бэкенд увеличивает его оценку: 20ms * 11 > 200ms * 1
+	fast.ActiveConnections = 10
+	if backend := pool.NextBackend(); backend != slow {
+		t.Fatalf("expected slow backend when fast one is busy, got %v", backend)
+	}
+}
+
+func TestPeakEWMAUnsampledBackend(t *testing.T) {
+	pool := NewServerPool([]string{"http://known", "http://fresh"})
+	known, fresh := pool.AllBackends()[0], pool.AllBackends()[1]
+	pool.SetStrategy(NewPeakEWMAStrategy())
+	pool.Done(known, OutcomeSuccess, 50*time.Millisecond)
+
+	// Свободный новый бэкенд получает запрос, чтобы появился замер
+	if backend := pool.NextBackend(); backend != fresh {
+		t.Fatalf("expected idle fresh backend to be probed, got %v", backend)
+	}
+
+	// Пока запрос в работе, новый бэкенд штрафуется и не заваливается запросами
+	fresh.IncConnections()
+	if backend := pool.NextBackend(); backend != known {
+		t.Fatalf("expected busy fresh backend to be penalized, got %v", backend)
+	}
+}
+
+func TestObserveLatencyPeakAndDecay(t *testing.T) {
+	var l latencyState
+	now := time.Now()
+
+	l.observe(10*time.Millisecond, time.Second, now)
+	l.observe(100*time.Millisecond, time.Second, now.Add(10*time.Millisecond))
+	if time.Duration(l.ewma) != 100*time.Millisecond {
+		t.Fatalf("expected peak to be taken immediately, got %v", time.Duration(l.ewma))
+	}
+
+	// Через одну постоянную затухания вес старого значения — 1/e
+	l.observe(10*time.Millisecond, time.Second, now.Add(10*time.Millisecond+time.Second))
+	got := time.Duration(l.ewma)
+	if got < 40*time.Millisecond || got > 50*time.Millisecond {
+		t.Errorf("expected decayed value around 43ms, got %v", got)
+	}
+}
+
+func TestDoneIgnoresTransportErrorLatency(t *testing.T) {
+	pool := NewServerPool([]string{"http://a"})
+	b := pool.AllBackends()[0]
+
+	pool.Done(b, OutcomeError, time.Millisecond)
+	if _, sampled := b.LatencyEWMA(); sampled {
+		t.Error("expected transport error latency to be ignored")
+	}
+}
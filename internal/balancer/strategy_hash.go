@@ -0,0 +1,188 @@
+package balancer
+
+import (
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefaultVirtualNodes — число виртуальных узлов на единицу веса бэкенда.
+const DefaultVirtualNodes = 100
+
+// Источники ключа для ConsistentHashStrategy.
+const (
+	HashKeyClientIP = "ip"        // IP клиента, вычисленный прокси
+	HashKeyClientID = "client_id" // заголовок X-Client-ID
+	HashKeyHeader   = "header"    // произвольный заголовок, header:<имя>
+	HashKeyCookie   = "cookie"    // cookie, cookie:<имя>
+	HashKeyPath     = "path"      // путь запроса
+)
+
+// HashKey описывает, из какой части запроса берётся ключ хеширования.
+type HashKey struct {
+	Source string
+	Name   string // имя заголовка или cookie
+}
+
+// ParseHashKey разбирает строку вида "ip", "client_id", "path",
+// "header:<имя>" или "cookie:<имя>". Пустая строка означает "ip".
+func ParseHashKey(s string) (HashKey, error) {
+	source, name, _ := strings.Cut(strings.TrimSpace(s), ":")
+	switch source {
+	case "":
+		return HashKey{Source: HashKeyClientIP}, nil
+	case HashKeyClientIP, HashKeyClientID, HashKeyPath:
+		return HashKey{Source: source}, nil
+	case HashKeyHeader, HashKeyCookie:
+		if name == "" {
+			return HashKey{}, fmt.Errorf("hash key %q requires a name, e.g. %s:<name>", s, source)
+		}
+		return HashKey{Source: source, Name: name}, nil
+	default:
+		return HashKey{}, fmt.Errorf("unknown hash key source: %s", s)
+	}
+}
+
+// Extract возвращает значение ключа из запроса. Если нужной части нет,
+// используется IP клиента, чтобы запросы без ключа тоже распределялись стабильно.
+func (k HashKey) Extract(sc *SelectionContext) string {
+	r := sc.Request
+	if r == nil {
+		return sc.clientIP()
+	}
+
+	var key string
+	switch k.Source {
+	case HashKeyClientID:
+		key = r.Header.Get("X-Client-ID")
+	case HashKeyHeader:
+		key = r.Header.Get(k.Name)
+	case HashKeyCookie:
+		if c, err := r.Cookie(k.Name); err == nil {
+			key = c.Value
+		}
+	case HashKeyPath:
+		key = r.URL.Path
+	}
+	if key == "" {
+		key = sc.clientIP()
+	}
+	return key
+}
+
+// ConsistentHashStrategy направляет запросы с одинаковым ключом на один и тот же
+// бэкенд с помощью кольца хешей с виртуальными узлами. При выходе бэкенда из пула
+// переезжают только его ключи: они достаются следующим по кольцу живым узлам.
+type ConsistentHashStrategy struct {
+	key          HashKey
+	virtualNodes int
+
+	mu      sync.RWMutex
+	ring    []ringNode // отсортированы по hash
+	members []*Backend // бэкенды, по которым построено кольцо
+}
+
+type ringNode struct {
+	hash    uint64
+	backend *Backend
+}
+
+// NewConsistentHashStrategy создаёт стратегию с заданным ключом и числом
+// виртуальных узлов на единицу веса (0 — DefaultVirtualNodes).
+func NewConsistentHashStrategy(key HashKey, virtualNodes int) Strategy {
+	if virtualNodes <= 0 {
+		virtualNodes = DefaultVirtualNodes
+	}
+	return &ConsistentHashStrategy{key: key, virtualNodes: virtualNodes}
+}
+
+// Next без запроса ключа не имеет, поэтому выбирает первый живой узел кольца.
+func (s *ConsistentHashStrategy) Next(p *ServerPool) *Backend {
+	return s.lookup(p, 0, &SelectionContext{})
+}
+
+// Select выбирает бэкенд по хешу ключа запроса. Исключённые для запроса
+// бэкенды пропускаются так же, как мёртвые: повтор уходит на следующий узел кольца.
+func (s *ConsistentHashStrategy) Select(p *ServerPool, sc *SelectionContext) *Backend {
+	return s.lookup(p, hashString(s.key.Extract(sc)), sc)
+}
+
+// lookup находит первый доступный узел кольца по часовой стрелке от hash.
+func (s *ConsistentHashStrategy) lookup(p *ServerPool, hash uint64, sc *SelectionContext) *Backend {
+	ring := s.ringFor(p)
+	if len(ring) == 0 {
+		return nil
+	}
+
+	start := sort.Search(len(ring), func(i int) bool { return ring[i].hash >= hash })
+	for i := 0; i < len(ring); i++ {
+		node := ring[(start+i)%len(ring)]
+		if node.backend.IsAvailable() && !sc.IsExcluded(node.backend) {
+			return node.backend
+		}
+	}
+	return nil
+}
+
+// ringFor возвращает кольцо для пула, перестраивая его при изменении состава бэкендов
+// (добавлен, убран или заменён другим). Кольцо строится по всем бэкендам, а мёртвые
+// пропускаются при поиске, поэтому смена статуса Alive не требует перестроения.
+func (s *ConsistentHashStrategy) ringFor(p *ServerPool) []ringNode {
+	backends := p.AllBackends()
+
+	s.mu.RLock()
+	ring, members := s.ring, s.members
+	s.mu.RUnlock()
+	if ring != nil && sameBackends(members, backends) {
+		return ring
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.ring != nil && sameBackends(s.members, backends) {
+		return s.ring
+	}
+
+	ring = make([]ringNode, 0, len(backends)*s.virtualNodes)
+	for _, b := range backends {
+		for i := 0; i < s.virtualNodes*b.GetWeight(); i++ {
+			ring = append(ring, ringNode{hash: hashString(b.URL + "#" + strconv.Itoa(i)), backend: b})
+		}
+	}
+	sort.Slice(ring, func(i, j int) bool { return ring[i].hash < ring[j].hash })
+
+	// Копия: AllBackends отдаёт срез пула, который может меняться
+	s.ring, s.members = ring, append([]*Backend(nil), backends...)
+	return ring
+}
+
+// sameBackends сообщает, совпадают ли списки бэкендов поэлементно.
+func sameBackends(a, b []*Backend) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// hashString возвращает 64-битный FNV-1a хеш строки, перемешанный финализатором
+// splitmix64: у FNV близкие строки ("url#1", "url#2") дают близкие хеши,
+// и без перемешивания виртуальные узлы ложатся на кольцо неравномерно.
+func hashString(s string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(s))
+	x := h.Sum64()
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
@@ -0,0 +1,106 @@
+package balancer
+
+import (
+	"fmt"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestConsistentHashStrategy_SameKeySameBackend(t *testing.T) {
+	pool := NewServerPool([]string{"http://a", "http://b", "http://c"})
+	pool.SetStrategy(NewConsistentHashStrategy(HashKey{Source: HashKeyHeader, Name: "X-User"}, 0))
+
+	for i := 0; i < 20; i++ {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("X-User", fmt.Sprintf("user-%d", i))
+
+		first := pool.Select(NewSelectionContext(req, ""))
+		for j := 0; j < 5; j++ {
+			if got := pool.Select(NewSelectionContext(req, "")); got != first {
+				t.Fatalf("key user-%d moved from %s to %s", i, first.URL, got.URL)
+			}
+		}
+	}
+}
+
+func TestConsistentHashStrategy_MinimalMovement(t *testing.T) {
+	pool := NewServerPool([]string{"http://a", "http://b", "http://c", "http://d"})
+	pool.SetStrategy(NewConsistentHashStrategy(HashKey{Source: HashKeyPath}, 0))
+
+	const keys = 1000
+	before := make([]*Backend, keys)
+	for i := 0; i < keys; i++ {
+		before[i] = pool.Select(NewSelectionContext(httptest.NewRequest("GET", fmt.Sprintf("/item/%d", i), nil), ""))
+	}
+
+	dead := pool.AllBackends()[1]
+	dead.SetAlive(false)
+
+	for i := 0; i < keys; i++ {
+		after := pool.Select(NewSelectionContext(httptest.NewRequest("GET", fmt.Sprintf("/item/%d", i), nil), ""))
+		if after == dead {
+			t.Fatalf("key %d routed to dead backend", i)
+		}
+		// Переезжать должны только ключи выбывшего бэкенда
+		if before[i] != dead && after != before[i] {
+			t.Errorf("key %d moved from %s to %s although its backend is alive", i, before[i].URL, after.URL)
+		}
+	}
+}
+
+func TestConsistentHashStrategy_ClientIP(t *testing.T) {
+	pool := NewServerPool([]string{"http://a", "http://b", "http://c"})
+	pool.SetStrategy(NewConsistentHashStrategy(HashKey{Source: HashKeyClientIP}, 0))
+
+	req := httptest.NewRequest("GET", "/", nil)
+	other := httptest.NewRequest("GET", "/other", nil)
+	other.RemoteAddr = "198.51.100.1:4321"
+
+	first := pool.Select(NewSelectionContext(req, "203.0.113.7"))
+	if first != pool.Select(NewSelectionContext(other, "203.0.113.7")) {
+		t.Error("expected requests with the same client IP to hit the same backend")
+	}
+}
+
+func TestParseHashKey(t *testing.T) {
+	valid := map[string]HashKey{
+		"":              {Source: HashKeyClientIP},
+		"ip":            {Source: HashKeyClientIP},
+		"client_id":     {Source: HashKeyClientID},
+		"path":          {Source: HashKeyPath},
+		"header:X-User": {Source: HashKeyHeader, Name: "X-User"},
+		"cookie:sid":    {Source: HashKeyCookie, Name: "sid"},
+	}
+	for in, want := range valid {
+		got, err := ParseHashKey(in)
+		if err != nil || got != want {
+			t.Errorf("ParseHashKey(%q) = %+v, %v; want %+v", in, got, err, want)
+		}
+	}
+
+	for _, in := range []string{"header", "cookie:", "query:id"} {
+		if _, err := ParseHashKey(in); err == nil {
+			t.Errorf("ParseHashKey(%q): expected error", in)
+		}
+	}
+}
+
+func TestConsistentHashStrategy_RebuildsOnReplacedBackend(t *testing.T) {
+	strategy := NewConsistentHashStrategy(HashKey{Source: HashKeyPath}, 0)
+	before := NewServerPool([]string{"http://a", "http://b", "http://c"})
+	before.SetStrategy(strategy)
+	for i := 0; i < 100; i++ {
+		before.Select(NewSelectionContext(httptest.NewRequest("GET", fmt.Sprintf("/item/%d", i), nil), ""))
+	}
+
+	// Тот же размер, но c заменён на d: кольцо не должно ссылаться на c
+	after := NewServerPool([]string{"http://a", "http://b", "http://d"})
+	after.SetStrategy(strategy)
+	replaced := before.AllBackends()[2]
+	for i := 0; i < 100; i++ {
+		got := after.Select(NewSelectionContext(httptest.NewRequest("GET", fmt.Sprintf("/item/%d", i), nil), ""))
+		if got == replaced {
+			t.Fatalf("key %d routed to replaced backend %s", i, got.URL)
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package balancer
+
+import (
+	"math/rand"
+)
+
+// p2cAttempts — сколько раз пытаться выбрать случайный живой бэкенд,
+// прежде чем перейти к полному списку живых.
+const p2cAttempts = 3
+
+// P2CStrategy реализует "power of two random choices": берёт два случайных
+// живых бэкенда и отправляет запрос на менее загруженный. Даёт результат,
+// близкий к least connections, за постоянное время на запрос.
+type P2CStrategy struct{}
+
+func NewP2CStrategy() Strategy {
+	return &P2CStrategy{}
+}
+
+// Next выбирает лучший из двух случайных живых бэкендов.
+func (s *P2CStrategy) Next(p *ServerPool) *Backend {
+	backends := p.AllBackends()
+	n := len(backends)
+	if n == 0 {
+		return nil
+	}
+
+	first := randomAlive(backends, -1)
+	if first < 0 {
+		// Большая часть пула мертва: выбираем из полного списка живых
+		alive := p.GetAliveBackends()
+		switch len(alive) {
+		case 0:
+			return nil
+		case 1:
+			return alive[0]
+		}
+		i := rand.Intn(len(alive))
+		j := rand.Intn(len(alive) - 1)
+		if j >= i {
+			j++
+		}
+		return lessLoaded(alive[i], alive[j])
+	}
+
+	second := randomAlive(backends, first)
+	if second < 0 {
+		return backends[first]
+	}
+	return lessLoaded(backends[first], backends[second])
+}
+
+// randomAlive возвращает индекс случайного живого бэкенда, отличного от exclude,
+// или -1, если за p2cAttempts попыток такой не нашёлся.
+func randomAlive(backends []*Backend, exclude int) int {
+	n := len(backends)
+	if exclude >= 0 && n < 2 {
+		return -1
+	}
+	for attempt := 0; attempt < p2cAttempts; attempt++ {
+		i := rand.Intn(n)
+		if i == exclude {
+			i = (i + 1 + rand.Intn(n-1)) % n
+		}
+		if backends[i].IsAvailable() {
+			return i
+		}
+	}
+	return -1
+}
+
+// lessLoaded сравнивает два бэкенда по LatencyScore, если для обоих есть
+// замеры задержки, иначе — по числу активных соединений.
+func lessLoaded(a, b *Backend) *Backend {
+	_, aSampled := a.LatencyEWMA()
+	_, bSampled := b.LatencyEWMA()
+	if aSampled && bSampled {
+		if b.LatencyScore() < a.LatencyScore() {
+			return b
+		}
+		return a
+	}
+	if b.GetConnections() < a.GetConnections() {
+		return b
+	}
+	return a
+}
@@ -0,0 +1,60 @@
+package balancer
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestP2CStrategy_PicksLessLoaded(t *testing.T) {
+	pool := NewServerPool([]string{"http://a", "http://b"})
+	pool.AllBackends()[0].ActiveConnections = 10
+	pool.AllBackends()[1].ActiveConnections = 1
+	pool.SetStrategy(NewP2CStrategy())
+
+	// С двумя бэкендами оба всегда попадают в выборку
+	for i := 0; i < 20; i++ {
+		if backend := pool.NextBackend(); backend == nil || backend.URL != "http://b" {
+			t.Fatalf("expected less loaded http://b, got %v", backend)
+		}
+	}
+}
+
+func TestP2CStrategy_PrefersLatencyScore(t *testing.T) {
+	pool := NewServerPool([]string{"http://slow", "http://fast"})
+	slow, fast := pool.AllBackends()[0], pool.AllBackends()[1]
+	pool.Done(slow, OutcomeSuccess, 500*time.Millisecond)
+	pool.Done(fast, OutcomeSuccess, 10*time.Millisecond)
+
+	// У быстрого бэкенда больше соединений, но меньше оценка задержки
+	slow.ActiveConnections = 1
+	fast.ActiveConnections = 3
+	pool.SetStrategy(NewP2CStrategy())
+
+	if backend := pool.NextBackend(); backend != fast {
+		t.Fatalf("expected backend with lower latency score, got %v", backend)
+	}
+}
+
+func TestP2CStrategy_SkipsDeadBackends(t *testing.T) {
+	urls := make([]string, 10)
+	for i := range urls {
+		urls[i] = fmt.Sprintf("http://b%d", i)
+	}
+	pool := NewServerPool(urls)
+	for _, b := range pool.AllBackends()[1:] {
+		b.SetAlive(false)
+	}
+	pool.SetStrategy(NewP2CStrategy())
+
+	for i := 0; i < 50; i++ {
+		if backend := pool.NextBackend(); backend == nil || backend.URL != "http://b0" {
+			t.Fatalf("expected the only alive backend http://b0, got %v", backend)
+		}
+	}
+
+	pool.AllBackends()[0].SetAlive(false)
+	if backend := pool.NextBackend(); backend != nil {
+		t.Fatalf("expected nil when all backends are dead, got %s", backend.URL)
+	}
+}
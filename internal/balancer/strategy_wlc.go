@@ -0,0 +1,29 @@
+package balancer
+
+// WeightedLeastConnectionsStrategy выбирает бэкенд с наименьшим отношением
+// активных соединений к весу.
+type WeightedLeastConnectionsStrategy struct{}
+
+func NewWeightedLeastConnectionsStrategy() Strategy {
+	return &WeightedLeastConnectionsStrategy{}
+}
+
+// Next выбирает живой бэкенд с минимальным ActiveConnections/Weight.
+func (s *WeightedLeastConnectionsStrategy) Next(p *ServerPool) *Backend {
+	alive := p.GetAliveBackends()
+	if len(alive) == 0 {
+		return nil
+	}
+
+	var min *Backend
+	minConns, minWeight := 0, 1
+	for _, b := range alive {
+		conns, weight := b.GetConnections(), b.GetWeight()
+		// conns/weight < minConns/minWeight без деления
+		if min == nil || conns*minWeight < minConns*weight {
+			min = b
+			minConns, minWeight = conns, weight
+		}
+	}
+	return min
+}
@@ -0,0 +1,28 @@
+package balancer
+
+import (
+	"testing"
+)
+
+func TestWeightedLeastConnectionsStrategy(t *testing.T) {
+	pool := NewServerPoolFromBackends([]*Backend{
+		NewWeightedBackend("http://a", 1),
+		NewWeightedBackend("http://b", 4),
+		NewWeightedBackend("http://c", 2),
+	})
+
+	// Отношения соединений к весу: a=2, b=1.5, c=2.5
+	pool.AllBackends()[0].ActiveConnections = 2
+	pool.AllBackends()[1].ActiveConnections = 6
+	pool.AllBackends()[2].ActiveConnections = 5
+
+	pool.SetStrategy(NewWeightedLeastConnectionsStrategy())
+
+	backend := pool.NextBackend()
+	if backend == nil {
+		t.Fatal("expected backend, got nil")
+	}
+	if backend.URL != "http://b" {
+		t.Errorf("expected http://b with lowest connections per weight, got %s", backend.URL)
+	}
+}
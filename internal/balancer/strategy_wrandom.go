@@ -0,0 +1,34 @@
+package balancer
+
+import (
+	"math/rand"
+)
+
+// WeightedRandomStrategy выбирает случайный бэкенд с вероятностью,
+// пропорциональной его весу.
+type WeightedRandomStrategy struct{}
+
+func NewWeightedRandomStrategy() Strategy {
+	return &WeightedRandomStrategy{}
+}
+
+// Next выбирает случайный живой бэкенд с учётом весов.
+func (s *WeightedRandomStrategy) Next(p *ServerPool) *Backend {
+	alive := p.GetAliveBackends()
+	if len(alive) == 0 {
+		return nil
+	}
+
+	total := 0
+	for _, b := range alive {
+		total += b.GetWeight()
+	}
+	n := rand.Intn(total)
+	for _, b := range alive {
+		n -= b.GetWeight()
+		if n < 0 {
+			return b
+		}
+	}
+	return alive[len(alive)-1]
+}
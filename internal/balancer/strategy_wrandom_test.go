@@ -0,0 +1,30 @@
+package balancer_test
+
+import (
+	"testing"
+
+	"github.com/mk/loadBalancer/internal/balancer"
+)
+
+func TestWeightedRandomStrategy_Distribution(t *testing.T) {
+	pool := balancer.NewServerPoolFromBackends([]*balancer.Backend{
+		balancer.NewWeightedBackend("http://heavy", 9),
+		balancer.NewWeightedBackend("http://light", 1),
+	})
+	pool.SetStrategy(balancer.NewWeightedRandomStrategy())
+
+	counts := map[string]int{}
+	const total = 10000
+	for i := 0; i < total; i++ {
+		selected := pool.NextBackend()
+		if selected == nil {
+			t.Fatal("Expected a backend, got nil")
+		}
+		counts[selected.URL]++
+	}
+
+	// Ожидаем ~90% на тяжёлый бэкенд, допускаем разброс
+	if share := float64(counts["http://heavy"]) / total; share < 0.85 || share > 0.95 {
+		t.Errorf("Unexpected share for heavy backend: %.3f (%v)", share, counts)
+	}
+}
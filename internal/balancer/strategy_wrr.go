@@ -0,0 +1,55 @@
+package balancer
+
+import (
+	"sync"
+)
+
+// WeightedRoundRobin реализует плавный взвешенный round-robin (как в nginx):
+// бэкенды с большим весом выбираются чаще, но выборы перемежаются,
+// а не идут пачками подряд на самый тяжёлый узел.
+type WeightedRoundRobin struct {
+	mu      sync.Mutex
+	current map[*Backend]int // текущий вес каждого бэкенда
+}
+
+func NewWeightedRoundRobinStrategy() Strategy {
+	return &WeightedRoundRobin{current: make(map[*Backend]int)}
+}
+
+// Next выбирает живой бэкенд по алгоритму smooth weighted round-robin.
+func (s *WeightedRoundRobin) Next(p *ServerPool) *Backend {
+	alive := p.GetAliveBackends()
+	if len(alive) == 0 {
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var best *Backend
+	total := 0
+	for _, b := range alive {
+		w := b.GetWeight()
+		s.current[b] += w
+		total += w
+		if best == nil || s.current[b] > s.current[best] {
+			best = b
+		}
+	}
+	s.current[best] -= total
+
+	// Вес бэкендов, выбывших из пула или упавших, забывается: вернувшись, они
+	// начинают с нуля и не сбивают последовательность остальных
+	if len(s.current) > len(alive) {
+		live := make(map[*Backend]struct{}, len(alive))
+		for _, b := range alive {
+			live[b] = struct{}{}
+		}
+		for b := range s.current {
+			if _, ok := live[b]; !ok {
+				delete(s.current, b)
+			}
+		}
+	}
+	return best
+}
@@ -0,0 +1,73 @@
+package balancer
+
+import (
+	"testing"
+)
+
+func TestWeightedRoundRobinStrategy(t *testing.T) {
+	pool := NewServerPoolFromBackends([]*Backend{
+		NewWeightedBackend("http://a", 5),
+		NewWeightedBackend("http://b", 1),
+		NewWeightedBackend("http://c", 1),
+	})
+	pool.SetStrategy(NewWeightedRoundRobinStrategy())
+
+	got := []string{}
+	for i := 0; i < 7; i++ {
+		backend := pool.NextBackend()
+		if backend == nil {
+			t.Fatalf("expected backend, got nil")
+		}
+		got = append(got, backend.URL)
+	}
+
+	// Последовательность smooth WRR из nginx для весов {5, 1, 1}
+	expected := []string{"http://a", "http://a", "http://b", "http://a", "http://c", "http://a", "http://a"}
+	for i := range expected {
+		if got[i] != expected[i] {
+			t.Errorf("weighted round robin mismatch at %d: got %s, want %s", i, got[i], expected[i])
+		}
+	}
+}
+
+func TestWeightedRoundRobinSkipsDeadBackends(t *testing.T) {
+	pool := NewServerPoolFromBackends([]*Backend{
+		NewWeightedBackend("http://a", 3),
+		NewWeightedBackend("http://b", 1),
+	})
+	pool.AllBackends()[0].SetAlive(false)
+	pool.SetStrategy(NewWeightedRoundRobinStrategy())
+
+	for i := 0; i < 4; i++ {
+		if backend := pool.NextBackend(); backend == nil || backend.URL != "http://b" {
+			t.Fatalf("expected only http://b to be selected, got %v", backend)
+		}
+	}
+}
+
+func TestWeightedRoundRobinResetsReturningBackend(t *testing.T) {
+	pool := NewServerPoolFromBackends([]*Backend{
+		NewWeightedBackend("http://a", 5),
+		NewWeightedBackend("http://b", 1),
+		NewWeightedBackend("http://c", 1),
+	})
+	strategy := NewWeightedRoundRobinStrategy()
+	pool.SetStrategy(strategy)
+
+	// Несколько выборов оставляют a с отрицательным текущим весом
+	pool.NextBackend()
+	a := pool.AllBackends()[0]
+	a.SetAlive(false)
+	for i := 0; i < 3; i++ {
+		pool.NextBackend()
+	}
+	if _, ok := strategy.(*WeightedRoundRobin).current[a]; ok {
+		t.Fatalf("expected state of the dead backend to be dropped")
+	}
+
+	// Вернувшийся бэкенд начинает с нуля и сразу выбирается первым по весу
+	a.SetAlive(true)
+	if backend := pool.NextBackend(); backend != a {
+		t.Fatalf("expected returning heavy backend to be picked first, got %v", backend.URL)
+	}
+}
@@ -0,0 +1,92 @@
+package certs
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Поля клиентского сертификата, доступные как идентификатор клиента и для
+// передачи бэкендам.
+const (
+	FieldSubject     = "subject"     // Subject целиком, например "CN=billing,O=Example"
+	FieldCN          = "cn"          // Common Name
+	FieldSANDNS      = "san_dns"     // DNS-имена из SAN через запятую
+	FieldSANURI      = "san_uri"     // URI из SAN (например, SPIFFE ID) через запятую
+	FieldSANEmail    = "san_email"   // адреса почты из SAN через запятую
+	FieldFingerprint = "fingerprint" // SHA-256 сертификата, hex
+	FieldSerial      = "serial"      // серийный номер, hex
+)
+
+// ParseClientAuth переводит режим проверки клиентских сертификатов:
+// "none" (или пусто) — не запрашивать, "optional" — проверять, если
+// предъявлен, "require" — без проверенного сертификата соединение не принимается.
+func ParseClientAuth(mode string) (tls.ClientAuthType, error) {
+	switch mode {
+	case "", "none":
+		return tls.NoClientCert, nil
+	case "optional":
+		return tls.VerifyClientCertIfGiven, nil
+	case "require":
+		return tls.RequireAndVerifyClientCert, nil
+	}
+	return 0, fmt.Errorf("certs: unknown client auth mode %q", mode)
+}
+
+// ValidField сообщает, известно ли поле сертификата.
+func ValidField(field string) bool {
+	switch field {
+	case FieldSubject, FieldCN, FieldSANDNS, FieldSANURI, FieldSANEmail, FieldFingerprint, FieldSerial:
+		return true
+	}
+	return false
+}
+
+// Field возвращает значение поля сертификата. Для неизвестного поля — пустую строку.
+func Field(cert *x509.Certificate, field string) string {
+	switch field {
+	case FieldSubject:
+		return cert.Subject.String()
+	case FieldCN:
+		return cert.Subject.CommonName
+	case FieldSANDNS:
+		return strings.Join(cert.DNSNames, ",")
+	case FieldSANURI:
+		uris := make([]string, 0, len(cert.URIs))
+		for _, u := range cert.URIs {
+			uris = append(uris, u.String())
+		}
+		return strings.Join(uris, ",")
+	case FieldSANEmail:
+		return strings.Join(cert.EmailAddresses, ",")
+	case FieldFingerprint:
+		sum := sha256.Sum256(cert.Raw)
+		return hex.EncodeToString(sum[:])
+	case FieldSerial:
+		return cert.SerialNumber.Text(16)
+	}
+	return ""
+}
+
+// VerifiedClientCert возвращает клиентский сертификат запроса, прошедший
+// проверку по CA листенера, или nil.
+func VerifiedClientCert(r *http.Request) *x509.Certificate {
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 || len(r.TLS.VerifiedChains[0]) == 0 {
+		return nil
+	}
+	return r.TLS.VerifiedChains[0][0]
+}
+
+// ClientIdentity возвращает поле field проверенного клиентского сертификата
+// запроса или пустую строку, если сертификата нет.
+func ClientIdentity(r *http.Request, field string) string {
+	cert := VerifiedClientCert(r)
+	if cert == nil {
+		return ""
+	}
+	return Field(cert, field)
+}
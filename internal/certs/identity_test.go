@@ -0,0 +1,81 @@
+package certs
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"math/big"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func TestCertificateFields(t *testing.T) {
+	spiffe, _ := url.Parse("spiffe://example.org/billing")
+	cert := &x509.Certificate{
+		Raw:            []byte("certificate"),
+		SerialNumber:   big.NewInt(0xbeef),
+		Subject:        pkix.Name{CommonName: "billing", Organization: []string{"Example"}},
+		DNSNames:       []string{"billing", "billing.internal"},
+		URIs:           []*url.URL{spiffe},
+		EmailAddresses: []string{"billing@example.org"},
+	}
+	fingerprint := sha256.Sum256(cert.Raw)
+	cases := map[string]string{
+		FieldSubject:     "CN=billing,O=Example",
+		FieldCN:          "billing",
+		FieldSANDNS:      "billing,billing.internal",
+		FieldSANURI:      "spiffe://example.org/billing",
+		FieldSANEmail:    "billing@example.org",
+		FieldFingerprint: hex.EncodeToString(fingerprint[:]),
+		FieldSerial:      "beef",
+	}
+	for field, want := range cases {
+		if !ValidField(field) {
+			t.Errorf("Expected %s to be a valid field", field)
+		}
+		if got := Field(cert, field); got != want {
+			t.Errorf("Field %s: expected %q, got %q", field, want, got)
+		}
+	}
+	if ValidField("issuer") {
+		t.Error("Expected unknown field to be rejected")
+	}
+}
+
+func TestClientIdentityRequiresVerifiedChain(t *testing.T) {
+	cert := &x509.Certificate{Subject: pkix.Name{CommonName: "billing"}}
+
+	req := httptest.NewRequest("GET", "/", nil)
+	if got := ClientIdentity(req, FieldCN); got != "" {
+		t.Fatalf("Expected no identity without TLS, got %q", got)
+	}
+	// Предъявленный, но не проверенный сертификат не даёт идентификатора
+	req.TLS = &tls.ConnectionState{PeerCertificates: []*x509.Certificate{cert}}
+	if got := ClientIdentity(req, FieldCN); got != "" {
+		t.Fatalf("Expected no identity for unverified certificate, got %q", got)
+	}
+	req.TLS.VerifiedChains = [][]*x509.Certificate{{cert}}
+	if got := ClientIdentity(req, FieldCN); got != "billing" {
+		t.Fatalf("Expected billing, got %q", got)
+	}
+}
+
+func TestParseClientAuth(t *testing.T) {
+	cases := map[string]tls.ClientAuthType{
+		"":         tls.NoClientCert,
+		"none":     tls.NoClientCert,
+		"optional": tls.VerifyClientCertIfGiven,
+		"require":  tls.RequireAndVerifyClientCert,
+	}
+	for mode, want := range cases {
+		if got, err := ParseClientAuth(mode); err != nil || got != want {
+			t.Errorf("Mode %q: expected %v, got %v (%v)", mode, want, got, err)
+		}
+	}
+	if _, err := ParseClientAuth("request"); err == nil {
+		t.Error("Expected error for unknown mode")
+	}
+}
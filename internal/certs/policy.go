@@ -0,0 +1,121 @@
+package certs
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+)
+
+// ParseVersion переводит "1.0" … "1.3" в константу crypto/tls.
+// Пустая строка означает TLS 1.2.
+func ParseVersion(version string) (uint16, error) {
+	switch version {
+	case "1.0":
+		return tls.VersionTLS10, nil
+	case "1.1":
+		return tls.VersionTLS11, nil
+	case "", "1.2":
+		return tls.VersionTLS12, nil
+	case "1.3":
+		return tls.VersionTLS13, nil
+	}
+	return 0, fmt.Errorf("certs: unknown TLS version %q", version)
+}
+
+// ParseCipherSuites переводит имена наборов шифров (как в crypto/tls,
+// например TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256) в их идентификаторы.
+// Допускаются только наборы без известных уязвимостей. Пустой список
+// оставляет выбор за crypto/tls. Наборы TLS 1.3 не настраиваются.
+func ParseCipherSuites(names []string) ([]uint16, error) {
+	if len(names) == 0 {
+		return nil, nil
+	}
+	known := make(map[string]uint16)
+	for _, suite := range tls.CipherSuites() {
+		known[suite.Name] = suite.ID
+	}
+	ids := make([]uint16, 0, len(names))
+	for _, name := range names {
+		id, ok := known[name]
+		if !ok {
+			return nil, fmt.Errorf("certs: unknown or insecure cipher suite %q", name)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// ServerConfig собирает tls.Config листенера: сертификаты из store,
+// минимальная версия и наборы шифров.
+func ServerConfig(store *Store, minVersion string, cipherSuites []string) (*tls.Config, error) {
+	version, err := ParseVersion(minVersion)
+	if err != nil {
+		return nil, err
+	}
+	suites, err := ParseCipherSuites(cipherSuites)
+	if err != nil {
+		return nil, err
+	}
+	return &tls.Config{
+		GetCertificate: store.GetCertificate,
+		MinVersion:     version,
+		CipherSuites:   suites,
+	}, nil
+}
+
+// ConfigureClientAuth включает проверку клиентских сертификатов в режиме mode
+// (см. ParseClientAuth) по корневым сертификатам из caFile.
+func ConfigureClientAuth(cfg *tls.Config, mode, caFile string) error {
+	clientAuth, err := ParseClientAuth(mode)
+	if err != nil {
+		return err
+	}
+	if clientAuth == tls.NoClientCert {
+		return nil
+	}
+	pool, err := LoadCAPool(caFile)
+	if err != nil {
+		return err
+	}
+	cfg.ClientAuth = clientAuth
+	cfg.ClientCAs = pool
+	return nil
+}
+
+// LoadCAPool читает PEM-бандл доверенных корневых сертификатов.
+func LoadCAPool(file string) (*x509.CertPool, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("certs: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("certs: no certificates found in %s", file)
+	}
+	return pool, nil
+}
+
+// ClientConfig собирает tls.Config для соединений с бэкендами. caFile
+// заменяет системные корневые сертификаты, serverName — имя, которое
+// проверяется в сертификате бэкенда (и отправляется в SNI) вместо хоста из
+// URL. client, если задан, предъявляется бэкенду при mTLS.
+// insecureSkipVerify отключает проверку сертификата бэкенда — только для разработки.
+func ClientConfig(caFile, serverName string, client *Store, insecureSkipVerify bool) (*tls.Config, error) {
+	cfg := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		ServerName:         serverName,
+		InsecureSkipVerify: insecureSkipVerify,
+	}
+	if caFile != "" {
+		pool, err := LoadCAPool(caFile)
+		if err != nil {
+			return nil, err
+		}
+		cfg.RootCAs = pool
+	}
+	if client != nil {
+		cfg.GetClientCertificate = client.GetClientCertificate
+	}
+	return cfg, nil
+}
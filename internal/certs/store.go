@@ -0,0 +1,199 @@
+package certs
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Pair — пути к сертификату и закрытому ключу в PEM.
+type Pair struct {
+	CertFile string
+	KeyFile  string
+}
+
+// Store хранит сертификаты для TLS-листенера и выбирает их по SNI.
+// Файлы перечитываются, когда меняются на диске; при ошибке загрузки
+// продолжают использоваться прежние сертификаты.
+type Store struct {
+	pairs  []Pair
+	logger *zap.SugaredLogger
+
+	reloadMu sync.Mutex // Reload не выполняется параллельно
+
+	mu      sync.RWMutex
+	certs   []*tls.Certificate          // в порядке pairs; первый — сертификат по умолчанию
+	byName  map[string]*tls.Certificate // имя из сертификата (в т.ч. *.example.com) -> сертификат
+	stamps  []fileStamp                 // состояние файлов на момент последней загрузки
+	loadErr []error                     // последняя ошибка загрузки каждой пары, чтобы не повторять её в логе
+}
+
+// fileStamp — признаки изменения пары файлов.
+type fileStamp struct {
+	certMod, keyMod   time.Time
+	certSize, keySize int64
+}
+
+// NewStore загружает сертификаты. Любая ошибка загрузки при старте фатальна.
+func NewStore(pairs []Pair, logger *zap.SugaredLogger) (*Store, error) {
+	if len(pairs) == 0 {
+		return nil, errors.New("certs: no certificates configured")
+	}
+	s := &Store{
+		pairs:   pairs,
+		logger:  logger,
+		certs:   make([]*tls.Certificate, len(pairs)),
+		stamps:  make([]fileStamp, len(pairs)),
+		loadErr: make([]error, len(pairs)),
+	}
+	for i, p := range pairs {
+		cert, stamp, err := load(p)
+		if err != nil {
+			return nil, err
+		}
+		s.certs[i], s.stamps[i] = cert, stamp
+	}
+	s.index()
+	return s, nil
+}
+
+// load читает пару файлов и разбирает сертификат.
+func load(p Pair) (*tls.Certificate, fileStamp, error) {
+	stamp, err := stat(p)
+	if err != nil {
+		return nil, fileStamp{}, err
+	}
+	cert, err := tls.LoadX509KeyPair(p.CertFile, p.KeyFile)
+	if err != nil {
+		return nil, fileStamp{}, fmt.Errorf("certs: load %s: %w", p.CertFile, err)
+	}
+	if cert.Leaf == nil {
+		if cert.Leaf, err = x509.ParseCertificate(cert.Certificate[0]); err != nil {
+			return nil, fileStamp{}, fmt.Errorf("certs: parse %s: %w", p.CertFile, err)
+		}
+	}
+	return &cert, stamp, nil
+}
+
+func stat(p Pair) (fileStamp, error) {
+	certInfo, err := os.Stat(p.CertFile)
+	if err != nil {
+		return fileStamp{}, fmt.Errorf("certs: %w", err)
+	}
+	keyInfo, err := os.Stat(p.KeyFile)
+	if err != nil {
+		return fileStamp{}, fmt.Errorf("certs: %w", err)
+	}
+	return fileStamp{
+		certMod: certInfo.ModTime(), keyMod: keyInfo.ModTime(),
+		certSize: certInfo.Size(), keySize: keyInfo.Size(),
+	}, nil
+}
+
+// index перестраивает таблицу имён. Вызывается под s.mu (или до публикации Store).
+// При совпадении имён выигрывает сертификат, объявленный раньше.
+func (s *Store) index() {
+	byName := make(map[string]*tls.Certificate)
+	for _, cert := range s.certs {
+		names := cert.Leaf.DNSNames
+		if len(names) == 0 && cert.Leaf.Subject.CommonName != "" {
+			names = []string{cert.Leaf.Subject.CommonName}
+		}
+		for _, name := range names {
+			name = strings.ToLower(name)
+			if _, ok := byName[name]; !ok {
+				byName[name] = cert
+			}
+		}
+	}
+	s.byName = byName
+}
+
+// GetCertificate выбирает сертификат по SNI: точное имя, затем wildcard на
+// один уровень, иначе — первый сертификат из конфигурации. Подходит для
+// tls.Config.GetCertificate.
+func (s *Store) GetCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	name := strings.ToLower(strings.TrimSuffix(hello.ServerName, "."))
+	if name != "" {
+		if cert, ok := s.byName[name]; ok {
+			return cert, nil
+		}
+		if i := strings.IndexByte(name, '.'); i > 0 {
+			if cert, ok := s.byName["*"+name[i:]]; ok {
+				return cert, nil
+			}
+		}
+	}
+	return s.certs[0], nil
+}
+
+// GetClientCertificate возвращает первый сертификат — клиентский сертификат
+// для mTLS с бэкендами. Подходит для tls.Config.GetClientCertificate.
+func (s *Store) GetClientCertificate(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.certs[0], nil
+}
+
+// Reload перечитывает пары, файлы которых изменились. Возвращает true, если
+// хотя бы один сертификат заменён. Пара, которая не загрузилась (например,
+// сертификат уже обновлён, а ключ ещё нет), остаётся прежней и будет
+// перечитана при следующем вызове.
+func (s *Store) Reload() (bool, error) {
+	s.reloadMu.Lock()
+	defer s.reloadMu.Unlock()
+	var errs []error
+	changed := false
+	for i, p := range s.pairs {
+		stamp, err := stat(p)
+		if err == nil {
+			s.mu.RLock()
+			same := stamp == s.stamps[i]
+			s.mu.RUnlock()
+			if same {
+				continue
+			}
+			var cert *tls.Certificate
+			if cert, stamp, err = load(p); err == nil {
+				s.mu.Lock()
+				s.certs[i], s.stamps[i] = cert, stamp
+				s.index()
+				s.mu.Unlock()
+				s.loadErr[i] = nil
+				changed = true
+				s.logger.Infow("TLS certificate reloaded", "cert_file", p.CertFile, "names", cert.Leaf.DNSNames, "not_after", cert.Leaf.NotAfter)
+				continue
+			}
+		}
+		if s.loadErr[i] == nil || s.loadErr[i].Error() != err.Error() {
+			s.logger.Warnw("TLS certificate reload failed, keeping the previous one", "cert_file", p.CertFile, "error", err)
+		}
+		s.loadErr[i] = err
+		errs = append(errs, err)
+	}
+	return changed, errors.Join(errs...)
+}
+
+// Watch проверяет файлы каждые interval, пока не отменён ctx.
+func (s *Store) Watch(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.Reload()
+		}
+	}
+}
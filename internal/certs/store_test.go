@@ -0,0 +1,131 @@
+package certs_test
+
+import (
+	"crypto/tls"
+	"os"
+	"testing"
+
+	"github.com/mk/loadBalancer/internal/certs"
+	"github.com/mk/loadBalancer/internal/certs/certstest"
+	"go.uber.org/zap"
+)
+
+func served(t *testing.T, s *certs.Store, serverName string) string {
+	t.Helper()
+	cert, err := s.GetCertificate(&tls.ClientHelloInfo{ServerName: serverName})
+	if err != nil {
+		t.Fatalf("GetCertificate(%q): %v", serverName, err)
+	}
+	return cert.Leaf.Subject.CommonName
+}
+
+func TestStoreSelectsBySNI(t *testing.T) {
+	dir := t.TempDir()
+	s, err := certs.NewStore([]certs.Pair{
+		certstest.WriteCert(t, dir, "default", "default.test"),
+		certstest.WriteCert(t, dir, "api", "api.example.com"),
+		certstest.WriteCert(t, dir, "wildcard", "*.example.com"),
+	}, zap.NewNop().Sugar())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := map[string]string{
+		"api.example.com":     "api.example.com",
+		"API.Example.com.":    "api.example.com",
+		"www.example.com":     "*.example.com",
+		"a.b.example.com":     "default.test", // wildcard покрывает только один уровень
+		"example.com":         "default.test",
+		"":                    "default.test",
+		"unknown.example.org": "default.test",
+	}
+	for name, want := range cases {
+		if got := served(t, s, name); got != want {
+			t.Errorf("SNI %q: expected %s, got %s", name, want, got)
+		}
+	}
+}
+
+func TestStoreReloadsChangedFiles(t *testing.T) {
+	dir := t.TempDir()
+	pair := certstest.WriteCert(t, dir, "site", "old.example.com")
+	s, err := certs.NewStore([]certs.Pair{pair}, zap.NewNop().Sugar())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if changed, err := s.Reload(); changed || err != nil {
+		t.Fatalf("Expected no reload for unchanged files, got %v, %v", changed, err)
+	}
+
+	certstest.WriteCert(t, dir, "site", "new.example.com")
+	if changed, err := s.Reload(); !changed || err != nil {
+		t.Fatalf("Expected reload, got %v, %v", changed, err)
+	}
+	if got := served(t, s, "new.example.com"); got != "new.example.com" {
+		t.Fatalf("Expected the new certificate, got %s", got)
+	}
+}
+
+func TestStoreKeepsCertificateOnBrokenReload(t *testing.T) {
+	dir := t.TempDir()
+	pair := certstest.WriteCert(t, dir, "site", "site.example.com")
+	s, err := certs.NewStore([]certs.Pair{pair}, zap.NewNop().Sugar())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Сертификат заменён, а ключ ещё старый: пара не сходится
+	other := certstest.WriteCert(t, dir, "other", "next.example.com")
+	data, _ := os.ReadFile(other.CertFile)
+	certstest.WriteFile(t, pair.CertFile, data)
+	if changed, err := s.Reload(); changed || err == nil {
+		t.Fatalf("Expected failed reload, got %v, %v", changed, err)
+	}
+	if got := served(t, s, "site.example.com"); got != "site.example.com" {
+		t.Fatalf("Expected the previous certificate to stay, got %s", got)
+	}
+
+	// Когда ключ дописан, пара подхватывается при следующей проверке
+	data, _ = os.ReadFile(other.KeyFile)
+	certstest.WriteFile(t, pair.KeyFile, data)
+	if changed, err := s.Reload(); !changed || err != nil {
+		t.Fatalf("Expected reload, got %v, %v", changed, err)
+	}
+	if got := served(t, s, "next.example.com"); got != "next.example.com" {
+		t.Fatalf("Expected the new certificate, got %s", got)
+	}
+}
+
+func TestNewStoreRejectsMissingFiles(t *testing.T) {
+	if _, err := certs.NewStore([]certs.Pair{{CertFile: "/nonexistent.crt", KeyFile: "/nonexistent.key"}}, zap.NewNop().Sugar()); err == nil {
+		t.Fatal("Expected error for missing files")
+	}
+	if _, err := certs.NewStore(nil, zap.NewNop().Sugar()); err == nil {
+		t.Fatal("Expected error for empty certificate list")
+	}
+}
+
+func TestServerConfigPolicy(t *testing.T) {
+	dir := t.TempDir()
+	s, err := certs.NewStore([]certs.Pair{certstest.WriteCert(t, dir, "site", "site.example.com")}, zap.NewNop().Sugar())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := certs.ServerConfig(s, "1.3", nil)
+	if err != nil || cfg.MinVersion != tls.VersionTLS13 {
+		t.Fatalf("Expected TLS 1.3 minimum, got %+v, %v", cfg, err)
+	}
+	cfg, err = certs.ServerConfig(s, "", []string{"TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256"})
+	if err != nil || cfg.MinVersion != tls.VersionTLS12 || len(cfg.CipherSuites) != 1 ||
+		cfg.CipherSuites[0] != tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256 {
+		t.Fatalf("Unexpected config %+v, %v", cfg, err)
+	}
+	if _, err := certs.ServerConfig(s, "1.4", nil); err == nil {
+		t.Fatal("Expected error for unknown version")
+	}
+	if _, err := certs.ServerConfig(s, "1.2", []string{"TLS_RSA_WITH_RC4_128_SHA"}); err == nil {
+		t.Fatal("Expected error for insecure cipher suite")
+	}
+}
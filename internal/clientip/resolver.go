@@ -0,0 +1,188 @@
+// Пакет clientip определяет IP клиента за цепочкой доверенных прокси.
+//
+// Заголовкам Forwarded, X-Forwarded-For и X-Real-IP верят только тогда, когда
+// непосредственный собеседник (RemoteAddr) входит в доверенные сети. Цепочка
+// адресов просматривается справа налево: первый адрес не из доверенных сетей
+// и есть клиент. Адреса левее него подделать может кто угодно.
+package clientip
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// Resolver определяет IP клиента с учётом доверенных прокси.
+type Resolver struct {
+	trusted []netip.Prefix
+}
+
+// NewResolver создаёт Resolver. cidrs — доверенные сети; допускаются и
+// отдельные адреса ("10.0.0.1" равносильно "10.0.0.1/32").
+func NewResolver(cidrs []string) (*Resolver, error) {
+	r := &Resolver{}
+	for _, cidr := range cidrs {
+		cidr = strings.TrimSpace(cidr)
+		if !strings.Contains(cidr, "/") {
+			addr, err := netip.ParseAddr(cidr)
+			if err != nil {
+				return nil, fmt.Errorf("invalid trusted proxy %q: %w", cidr, err)
+			}
+			r.trusted = append(r.trusted, netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()))
+			continue
+		}
+		prefix, err := netip.ParsePrefix(cidr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy %q: %w", cidr, err)
+		}
+		r.trusted = append(r.trusted, prefix.Masked())
+	}
+	return r, nil
+}
+
+// Default — Resolver без доверенных прокси: IP клиента всегда берётся из соединения.
+var Default = &Resolver{}
+
+// IsTrusted сообщает, входит ли адрес в доверенные сети.
+func (r *Resolver) IsTrusted(ip string) bool {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+	for _, prefix := range r.trusted {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// PeerTrusted сообщает, пришёл ли запрос от доверенного прокси.
+func (r *Resolver) PeerTrusted(req *http.Request) bool {
+	return r.IsTrusted(PeerIP(req))
+}
+
+// ClientIP возвращает IP клиента запроса.
+func (r *Resolver) ClientIP(req *http.Request) string {
+	peer := PeerIP(req)
+	if !r.IsTrusted(peer) {
+		return peer
+	}
+
+	chain := ForwardedFor(req.Header)
+	if chain == nil {
+		chain = XForwardedFor(req.Header)
+	}
+	if chain == nil {
+		if ip := strings.TrimSpace(req.Header.Get("X-Real-IP")); ip != "" && validIP(ip) {
+			return ip
+		}
+		return peer
+	}
+
+	// Справа налево: пропускаем доверенные прокси, первый чужой адрес — клиент
+	client := peer
+	for i := len(chain) - 1; i >= 0; i-- {
+		hop := chain[i]
+		if !validIP(hop) {
+			// "unknown" или скрытый идентификатор: дальше цепочке верить нельзя
+			break
+		}
+		client = hop
+		if !r.IsTrusted(hop) {
+			break
+		}
+	}
+	return client
+}
+
+// PeerIP возвращает адрес непосредственного собеседника.
+func PeerIP(req *http.Request) string {
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		return req.RemoteAddr
+	}
+	return host
+}
+
+// XForwardedFor возвращает адреса из всех заголовков X-Forwarded-For по порядку.
+func XForwardedFor(h http.Header) []string {
+	var chain []string
+	for _, value := range h.Values("X-Forwarded-For") {
+		for _, ip := range strings.Split(value, ",") {
+			if ip = strings.TrimSpace(ip); ip != "" {
+				chain = append(chain, forwardedNode(ip))
+			}
+		}
+	}
+	return chain
+}
+
+// ForwardedFor возвращает адреса из параметров for= заголовков Forwarded
+// (RFC 7239) по порядку, без портов и скобок IPv6.
+func ForwardedFor(h http.Header) []string {
+	var chain []string
+	for _, value := range h.Values("Forwarded") {
+		for _, element := range splitQuoted(value, ',') {
+			for _, pair := range splitQuoted(element, ';') {
+				name, val, ok := strings.Cut(strings.TrimSpace(pair), "=")
+				if !ok || !strings.EqualFold(name, "for") {
+					continue
+				}
+				chain = append(chain, forwardedNode(val))
+			}
+		}
+	}
+	return chain
+}
+
+// forwardedNode извлекает адрес из значения for= или элемента X-Forwarded-For:
+// "[2001:db8::1]:4711" → "2001:db8::1", "192.0.2.1:80" → "192.0.2.1".
+func forwardedNode(v string) string {
+	v = strings.Trim(strings.TrimSpace(v), `"`)
+	if strings.HasPrefix(v, "[") {
+		if end := strings.IndexByte(v, ']'); end > 0 {
+			return v[1:end]
+		}
+		return v
+	}
+	if host, _, err := net.SplitHostPort(v); err == nil {
+		return host
+	}
+	return v
+}
+
+// FormatNode форматирует адрес для параметра for= заголовка Forwarded.
+func FormatNode(ip string) string {
+	if strings.Contains(ip, ":") {
+		return `"[` + ip + `]"`
+	}
+	return ip
+}
+
+// splitQuoted делит строку по sep, не разрывая строки в кавычках.
+func splitQuoted(s string, sep byte) []string {
+	var parts []string
+	inQuotes := false
+	start := 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '"':
+			inQuotes = !inQuotes
+		case sep:
+			if !inQuotes {
+				parts = append(parts, s[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(parts, s[start:])
+}
+
+func validIP(s string) bool {
+	_, err := netip.ParseAddr(s)
+	return err == nil
+}
@@ -0,0 +1,74 @@
+package clientip
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClientIP(t *testing.T) {
+	r, err := NewResolver([]string{"10.0.0.0/8", "192.0.2.1", "2001:db8::/32"})
+	if err != nil {
+		t.Fatalf("NewResolver: %v", err)
+	}
+
+	cases := []struct {
+		name    string
+		remote  string
+		headers map[string]string
+		want    string
+	}{
+		{"direct client", "203.0.113.5:1234", nil, "203.0.113.5"},
+		{"untrusted peer spoofs XFF", "203.0.113.5:1234", map[string]string{"X-Forwarded-For": "1.1.1.1"}, "203.0.113.5"},
+		{"untrusted peer spoofs X-Real-IP", "203.0.113.5:1234", map[string]string{"X-Real-IP": "1.1.1.1"}, "203.0.113.5"},
+		{"trusted proxy", "10.0.0.2:1234", map[string]string{"X-Forwarded-For": "198.51.100.7"}, "198.51.100.7"},
+		{"spoofed entry left of client", "10.0.0.2:1234", map[string]string{"X-Forwarded-For": "1.1.1.1, 198.51.100.7, 10.1.1.1"}, "198.51.100.7"},
+		{"all hops trusted", "10.0.0.2:1234", map[string]string{"X-Forwarded-For": "10.3.3.3, 10.1.1.1"}, "10.3.3.3"},
+		{"port in XFF", "10.0.0.2:1234", map[string]string{"X-Forwarded-For": "198.51.100.7:5555"}, "198.51.100.7"},
+		{"trusted X-Real-IP", "192.0.2.1:1234", map[string]string{"X-Real-IP": "198.51.100.8"}, "198.51.100.8"},
+		{"Forwarded wins over XFF", "10.0.0.2:1234", map[string]string{
+			"Forwarded":       `for=198.51.100.9;proto=https, for=10.1.1.1`,
+			"X-Forwarded-For": "1.1.1.1",
+		}, "198.51.100.9"},
+		{"Forwarded IPv6", "[2001:db8::1]:1234", map[string]string{"Forwarded": `for="[2001:db9::17]:4711"`}, "2001:db9::17"},
+		{"Forwarded unknown hop", "10.0.0.2:1234", map[string]string{"Forwarded": "for=198.51.100.9, for=unknown, for=10.1.1.1"}, "10.1.1.1"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.RemoteAddr = tc.remote
+			for k, v := range tc.headers {
+				req.Header.Set(k, v)
+			}
+			if got := r.ClientIP(req); got != tc.want {
+				t.Fatalf("expected %s, got %s", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestNewResolverRejectsInvalidCIDR(t *testing.T) {
+	if _, err := NewResolver([]string{"10.0.0.0/33"}); err == nil {
+		t.Fatal("expected error for invalid CIDR")
+	}
+	if _, err := NewResolver([]string{"not-an-ip"}); err == nil {
+		t.Fatal("expected error for invalid address")
+	}
+}
+
+func TestForwardedFor(t *testing.T) {
+	h := http.Header{}
+	h.Add("Forwarded", `for=192.0.2.60;proto=http;by=203.0.113.43, for="[2001:db8:cafe::17]:4711"`)
+	h.Add("Forwarded", `for="_hidden";host="a,b"`)
+
+	got := ForwardedFor(h)
+	want := []string{"192.0.2.60", "2001:db8:cafe::17", "_hidden"}
+	if len(got) != len(want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("expected %v, got %v", want, got)
+		}
+	}
+}
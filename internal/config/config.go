@@ -6,19 +6,226 @@ import (
 	"os"
 	"strconv" 
 	"strings" // для разделения списка backends
+	"time"
 
 	"gopkg.in/yaml.v2"
 )
 
+// BackendConfig описывает один бэкенд. В YAML допускается как структура
+// {url, weight}, так и прежняя форма — просто строка с URL.
+type BackendConfig struct {
+    URL    string `yaml:"url"`
+    Weight int    `yaml:"weight"` // относительный вес, по умолчанию 1
+}
+
+// UnmarshalYAML поддерживает обе формы записи бэкенда. Вес, указанный явно,
+// должен быть положительным: weight: 0 не подменяется весом по умолчанию.
+func (b *BackendConfig) UnmarshalYAML(unmarshal func(interface{}) error) error {
+    var url string
+    if err := unmarshal(&url); err == nil {
+        *b = BackendConfig{URL: url, Weight: 1}
+        return nil
+    }
+    var raw struct {
+        URL    string `yaml:"url"`
+        Weight *int   `yaml:"weight"`
+    }
+    if err := unmarshal(&raw); err != nil {
+        return err
+    }
+    *b = BackendConfig{URL: raw.URL, Weight: 1}
+    if raw.Weight != nil {
+        if *raw.Weight <= 0 {
+            return fmt.Errorf("invalid weight %d for backend %s: must be positive", *raw.Weight, raw.URL)
+        }
+        b.Weight = *raw.Weight
+    }
+    return nil
+}
+
+// DefaultPoolName — пул, который образуют backends и strategy верхнего уровня.
+const DefaultPoolName = "default"
+
+// HeaderOpsConfig — изменения заголовков. Значения set и add — шаблоны с
+// подстановками {client_ip}, {backend}, {backend_host}, {request_id}, {route},
+// {host}, {method}, {path}.
+type HeaderOpsConfig struct {
+    Set    map[string]string `yaml:"set"`    // заменить значение
+    Add    map[string]string `yaml:"add"`    // добавить ещё одно значение
+    Remove []string          `yaml:"remove"` // удалить заголовок
+}
+
+// CertificateConfig — сертификат и закрытый ключ в PEM.
+type CertificateConfig struct {
+    CertFile string `yaml:"cert_file"`
+    KeyFile  string `yaml:"key_file"`
+}
+
+// UpstreamTLSConfig — TLS к бэкендам пула с адресами https://.
+type UpstreamTLSConfig struct {
+    CAFile             string `yaml:"ca_file"`              // корневые сертификаты вместо системных
+    ServerName         string `yaml:"server_name"`          // имя для SNI и проверки сертификата вместо хоста из URL
+    CertFile           string `yaml:"cert_file"`            // клиентский сертификат для mTLS
+    KeyFile            string `yaml:"key_file"`             // ключ клиентского сертификата
+    InsecureSkipVerify bool   `yaml:"insecure_skip_verify"` // не проверять сертификат бэкенда (только для разработки)
+}
+
+// PoolConfig описывает именованный пул бэкендов со своей стратегией.
+type PoolConfig struct {
+    Strategy        string            `yaml:"strategy"` // по умолчанию — strategy верхнего уровня
+    Backends        []BackendConfig   `yaml:"backends"`
+    Protocol        string            `yaml:"protocol"`         // http1, http2 (по TLS) или h2c; пусто — HTTP/1.1 или HTTP/2 по ALPN
+    ProxyProtocol   string            `yaml:"proxy_protocol"`   // "v1" или "v2" — отправлять бэкендам заголовок PROXY protocol
+    TLS             UpstreamTLSConfig `yaml:"tls"`              // TLS к бэкендам https://, в том числе для health checks
+    HealthCheck     PoolHealthCheckConfig `yaml:"health_check"` // способ проверки бэкендов пула
+    RequestHeaders  HeaderOpsConfig   `yaml:"request_headers"`  // заголовки запроса к бэкенду
+    ResponseHeaders HeaderOpsConfig   `yaml:"response_headers"` // заголовки ответа клиенту
+}
+
+// PoolHealthCheckConfig — способ активной проверки бэкендов пула. Интервал,
+// таймаут и пороги общие и задаются в health_check верхнего уровня.
+type PoolHealthCheckConfig struct {
+    Type    string `yaml:"type"`    // http (GET health_check.path) или grpc (grpc.health.v1.Health/Check)
+    Service string `yaml:"service"` // сервис для проверки grpc; пусто — сервер целиком
+}
+
+// RouteConfig — правило таблицы маршрутизации. Запрос должен подходить под все
+// заданные условия; из подходящих правил выигрывает правило с наибольшим
+// priority, при равенстве — объявленное раньше.
+type RouteConfig struct {
+    Name       string            `yaml:"name"`
+    Pool       string            `yaml:"pool"`        // пул, в который уходит запрос
+    Host       string            `yaml:"host"`        // Host запроса, допускается *.example.com
+    PathPrefix string            `yaml:"path_prefix"` // префикс пути
+    PathRegex  string            `yaml:"path_regex"`  // регулярное выражение для пути
+    Methods    []string          `yaml:"methods"`     // HTTP-методы
+    Headers    map[string]string `yaml:"headers"`     // заголовки и их значения ("" — заголовок задан)
+    Priority   int               `yaml:"priority"`
+    StripPrefix bool             `yaml:"strip_prefix"` // снимать path_prefix перед отправкой на бэкенд
+    Rewrite    struct {
+        Regex       string `yaml:"regex"`       // выражение для пути
+        Replacement string `yaml:"replacement"` // подстановка, $1 — группы выражения
+    } `yaml:"rewrite"`
+    GRPC       struct {
+        Service string `yaml:"service"` // сервис gRPC (package.Service), правило подходит только вызовам gRPC
+        Method  string `yaml:"method"`  // метод сервиса
+    } `yaml:"grpc"`
+    RequestHeaders  HeaderOpsConfig `yaml:"request_headers"`  // применяются после правил пула
+    ResponseHeaders HeaderOpsConfig `yaml:"response_headers"` // применяются после правил пула
+}
+
 type Config struct {
-    Port         int      `yaml:"port"`
-    Backends     []string `yaml:"backends"`
+    Port         int             `yaml:"port"`
+    Backends     []BackendConfig `yaml:"backends"`
+    Pools        map[string]PoolConfig `yaml:"pools"`  // именованные пулы бэкендов
+    Routes       []RouteConfig         `yaml:"routes"` // таблица маршрутизации по пулам
     RateLimit    struct {
         Capacity   int `yaml:"capacity"`
         RefillRate int `yaml:"refill_rate"`
     } `yaml:"rate_limit"`
     DatabasePath string `yaml:"databasePath"` 
+    TrustedProxies []string `yaml:"trusted_proxies"` // сети прокси, чьим Forwarded/X-Forwarded-For можно верить
     Strategy     string `yaml:"strategy"` // добавляем стратегию
+    ConsistentHash struct {
+        Key          string `yaml:"key"`           // ip, client_id, path, header:<имя>, cookie:<имя>
+        VirtualNodes int    `yaml:"virtual_nodes"` // виртуальных узлов на единицу веса
+    } `yaml:"consistent_hash"`
+    PeakEWMA struct {
+        Decay time.Duration `yaml:"decay"` // постоянная затухания EWMA задержки
+    } `yaml:"peak_ewma"`
+    StickySessions struct {
+        Enabled    bool          `yaml:"enabled"`
+        CookieName string        `yaml:"cookie_name"` // имя cookie привязки
+        TTL        time.Duration `yaml:"ttl"`         // время жизни привязки
+        SigningKey string        `yaml:"signing_key"` // ключ HMAC-подписи cookie
+    } `yaml:"sticky_sessions"`
+    OutlierDetection struct {
+        Enabled                  bool          `yaml:"enabled"`
+        Consecutive5xx           int           `yaml:"consecutive_5xx"`            // 5xx подряд до исключения
+        ConsecutiveGatewayErrors int           `yaml:"consecutive_gateway_errors"` // 502/503/504 и ошибок соединения подряд
+        BaseEjectionTime         time.Duration `yaml:"base_ejection_time"`         // растёт с каждым повторным исключением
+        MaxEjectionTime          time.Duration `yaml:"max_ejection_time"`
+        MaxEjectionPercent       int           `yaml:"max_ejection_percent"` // доля пула, исключённая одновременно
+    } `yaml:"outlier_detection"`
+    CircuitBreaker struct {
+        Enabled          bool          `yaml:"enabled"`
+        Window           time.Duration `yaml:"window"`             // скользящее окно подсчёта ошибок
+        MinRequests      int           `yaml:"min_requests"`       // минимум запросов в окне
+        ErrorThreshold   float64       `yaml:"error_threshold"`    // доля ошибок для размыкания (0..1)
+        OpenTimeout      time.Duration `yaml:"open_timeout"`       // время в разомкнутом состоянии
+        HalfOpenRequests int           `yaml:"half_open_requests"` // пробных запросов в полуоткрытом
+    } `yaml:"circuit_breaker"`
+    Retries struct {
+        MaxAttempts   int           `yaml:"max_attempts"`    // всего попыток, включая первую
+        PerTryTimeout time.Duration `yaml:"per_try_timeout"` // до получения заголовков ответа
+        TotalTimeout  time.Duration `yaml:"total_timeout"`   // общий дедлайн запроса
+        MaxBodyBytes  int64         `yaml:"max_body_bytes"`  // предел буферизации тела для повтора
+        Budget        struct {
+            Percent      float64       `yaml:"percent"`         // доля повторов от потока запросов, %
+            MinPerSecond float64       `yaml:"min_per_second"`  // минимальная квота повторов в секунду
+            Window       time.Duration `yaml:"window"`          // скользящее окно подсчёта
+        } `yaml:"budget"`
+    } `yaml:"retries"`
+    TLS struct {
+        Enabled        bool                `yaml:"enabled"`
+        Port           int                 `yaml:"port"`            // порт HTTPS, по умолчанию 8443
+        Certificates   []CertificateConfig `yaml:"certificates"`    // выбираются по SNI; первый — по умолчанию
+        MinVersion     string              `yaml:"min_version"`     // "1.0" … "1.3", по умолчанию "1.2"
+        CipherSuites   []string            `yaml:"cipher_suites"`   // имена наборов из crypto/tls; пусто — по умолчанию Go
+        ReloadInterval time.Duration       `yaml:"reload_interval"` // период проверки файлов сертификатов
+        RedirectHTTP   bool                `yaml:"redirect_http"`   // port принимает HTTP и перенаправляет на HTTPS
+        ClientAuth     struct {
+            Mode     string            `yaml:"mode"`     // none, optional или require
+            CAFile   string            `yaml:"ca_file"`  // корневые сертификаты клиентов
+            Identity string            `yaml:"identity"` // поле сертификата — идентификатор клиента для rate limit
+            Headers  map[string]string `yaml:"headers"`  // заголовок к бэкенду -> поле сертификата
+        } `yaml:"client_auth"`
+    } `yaml:"tls"`
+    HTTP2 struct {
+        Disabled             bool   `yaml:"disabled"`               // не принимать HTTP/2 по TLS
+        H2C                  bool   `yaml:"h2c"`                    // принимать HTTP/2 без TLS на port
+        MaxConcurrentStreams uint32 `yaml:"max_concurrent_streams"` // одновременных потоков на соединение клиента
+    } `yaml:"http2"`
+    ProxyProtocol struct {
+        Enabled       bool          `yaml:"enabled"`        // принимать заголовок PROXY protocol v1/v2
+        Trusted       []string      `yaml:"trusted"`        // сети, от которых заголовок принимается
+        HeaderTimeout time.Duration `yaml:"header_timeout"` // ожидание заголовка после подключения
+    } `yaml:"proxy_protocol"`
+    Transport struct {
+        MaxIdleConns          int           `yaml:"max_idle_conns"`          // всего простаивающих соединений
+        MaxIdleConnsPerHost   int           `yaml:"max_idle_conns_per_host"` // простаивающих соединений на бэкенд
+        IdleConnTimeout       time.Duration `yaml:"idle_conn_timeout"`       // закрытие простаивающего соединения
+        DialTimeout           time.Duration `yaml:"dial_timeout"`            // установка TCP-соединения
+        KeepAlive             time.Duration `yaml:"keep_alive"`              // период TCP keep-alive
+        TLSHandshakeTimeout   time.Duration `yaml:"tls_handshake_timeout"`   // TLS-рукопожатие
+        ResponseHeaderTimeout time.Duration `yaml:"response_header_timeout"` // ожидание заголовков ответа (0 — без ограничения)
+        DisableKeepAlives     bool          `yaml:"disable_keep_alives"`     // новое соединение на каждый запрос
+        DisableHTTP2          bool          `yaml:"disable_http2"`           // не договариваться о HTTP/2 с бэкендами
+    } `yaml:"transport"`
+    Upgrades struct {
+        IdleTimeout time.Duration `yaml:"idle_timeout"` // закрыть WebSocket/Upgrade-соединение без данных
+        MaxLifetime time.Duration `yaml:"max_lifetime"` // максимальное время жизни соединения (0 — без ограничения)
+    } `yaml:"upgrades"`
+    Hedging struct {
+        Paths      []string      `yaml:"paths"`      // префиксы путей, для которых включено хеджирование
+        Delay      time.Duration `yaml:"delay"`      // задержка перед вторым запросом
+        Percentile float64       `yaml:"percentile"` // брать задержку из перцентиля наблюдаемых (0 — только delay)
+        Budget     struct {
+            Percent      float64       `yaml:"percent"`        // доля хеджирующих запросов, %
+            MinPerSecond float64       `yaml:"min_per_second"` // минимальная квота в секунду
+            Window       time.Duration `yaml:"window"`         // скользящее окно подсчёта
+        } `yaml:"budget"`
+    } `yaml:"hedging"`
+    HealthCheck  struct {
+        Interval       time.Duration `yaml:"interval"`        // период между проверками
+        Timeout        time.Duration `yaml:"timeout"`         // таймаут одной проверки
+        Path           string        `yaml:"path"`            // путь health check на бэкенде
+        ExpectedStatus int           `yaml:"expected_status"` // ожидаемый код ответа
+        FallThreshold  int           `yaml:"fall_threshold"`  // неудач подряд до исключения из пула
+        RiseThreshold  int           `yaml:"rise_threshold"`  // успехов подряд до возврата в пул
+        HoldDown       time.Duration `yaml:"hold_down"`       // минимальный интервал между сменами состояния
+        HistorySize    int           `yaml:"history_size"`    // сколько последних результатов хранить
+    } `yaml:"health_check"`
 }
 
 // Load загружает конфигурацию из файла и переменных окружения
@@ -44,7 +251,10 @@ func Load(path string) (*Config, error) {
 
     if backends := os.Getenv("BACKENDS"); backends != "" {
         // Разделяем список бэкендов по запятой и добавляем их
-        cfg.Backends = strings.Split(backends, ",")
+        cfg.Backends = nil
+        for _, url := range strings.Split(backends, ",") {
+            cfg.Backends = append(cfg.Backends, BackendConfig{URL: strings.TrimSpace(url)})
+        }
     }
 
     if dbPath := os.Getenv("DATABASE_PATH"); dbPath != "" {
@@ -55,5 +265,243 @@ func Load(path string) (*Config, error) {
         cfg.Strategy = strategy
     }
 
+    if proxies := os.Getenv("TRUSTED_PROXIES"); proxies != "" {
+        cfg.TrustedProxies = nil
+        for _, cidr := range strings.Split(proxies, ",") {
+            cfg.TrustedProxies = append(cfg.TrustedProxies, strings.TrimSpace(cidr))
+        }
+    }
+
+    if key := os.Getenv("STICKY_SIGNING_KEY"); key != "" {
+        cfg.StickySessions.SigningKey = key
+    }
+
+    applyDefaults(&cfg)
+
+    for name, pool := range cfg.Pools {
+        for _, b := range pool.Backends {
+            if b.URL == "" {
+                return nil, fmt.Errorf("backend url must not be empty in pool %q", name)
+            }
+            if b.Weight < 0 {
+                return nil, fmt.Errorf("invalid weight %d for backend %s", b.Weight, b.URL)
+            }
+        }
+    }
+    for name, pool := range cfg.Pools {
+        switch pool.ProxyProtocol {
+        case "", "v1", "v2":
+        default:
+            return nil, fmt.Errorf("pool %q: invalid proxy_protocol %q: must be v1 or v2", name, pool.ProxyProtocol)
+        }
+        switch pool.Protocol {
+        case "", "http1":
+        case "http2", "h2c":
+            if pool.ProxyProtocol != "" {
+                return nil, fmt.Errorf("pool %q: proxy_protocol cannot be used with protocol %s", name, pool.Protocol)
+            }
+        default:
+            return nil, fmt.Errorf("pool %q: invalid protocol %q: must be http1, http2 or h2c", name, pool.Protocol)
+        }
+        switch pool.HealthCheck.Type {
+        case "http":
+        case "grpc":
+            if pool.Protocol != "http2" && pool.Protocol != "h2c" {
+                return nil, fmt.Errorf("pool %q: health_check type grpc requires protocol http2 or h2c", name)
+            }
+        default:
+            return nil, fmt.Errorf("pool %q: invalid health_check type %q: must be http or grpc", name, pool.HealthCheck.Type)
+        }
+        if (pool.TLS.CertFile == "") != (pool.TLS.KeyFile == "") {
+            return nil, fmt.Errorf("pool %q: tls.cert_file and tls.key_file must be set together", name)
+        }
+    }
+    if cfg.TLS.Enabled {
+        if len(cfg.TLS.Certificates) == 0 {
+            return nil, fmt.Errorf("tls.certificates must not be empty when tls is enabled")
+        }
+        for i, cert := range cfg.TLS.Certificates {
+            if cert.CertFile == "" || cert.KeyFile == "" {
+                return nil, fmt.Errorf("tls.certificates[%d]: cert_file and key_file are required", i)
+            }
+        }
+        switch cfg.TLS.MinVersion {
+        case "1.0", "1.1", "1.2", "1.3":
+        default:
+            return nil, fmt.Errorf("invalid tls.min_version %q: must be 1.0, 1.1, 1.2 or 1.3", cfg.TLS.MinVersion)
+        }
+        if cfg.TLS.RedirectHTTP && cfg.TLS.Port == cfg.Port {
+            return nil, fmt.Errorf("tls.port must differ from port when tls.redirect_http is enabled")
+        }
+        switch ca := cfg.TLS.ClientAuth; ca.Mode {
+        case "none":
+        case "optional", "require":
+            if ca.CAFile == "" {
+                return nil, fmt.Errorf("tls.client_auth.ca_file is required for mode %q", ca.Mode)
+            }
+        default:
+            return nil, fmt.Errorf("invalid tls.client_auth.mode %q: must be none, optional or require", ca.Mode)
+        }
+    }
+    if cfg.ProxyProtocol.Enabled && len(cfg.ProxyProtocol.Trusted) == 0 {
+        return nil, fmt.Errorf("proxy_protocol.trusted must not be empty when proxy_protocol is enabled")
+    }
+    if len(cfg.Routes) == 0 {
+        return nil, fmt.Errorf("routes are required when pools are defined without top-level backends")
+    }
+    for i, route := range cfg.Routes {
+        if _, ok := cfg.Pools[route.Pool]; !ok {
+            return nil, fmt.Errorf("route %d (%s) refers to unknown pool %q", i, route.Name, route.Pool)
+        }
+        if strings.Contains(route.GRPC.Service, "/") || strings.Contains(route.GRPC.Method, "/") {
+            return nil, fmt.Errorf("route %d (%s): grpc service and method must not contain '/'", i, route.Name)
+        }
+    }
+    if p := cfg.Hedging.Percentile; p < 0 || p > 100 {
+        return nil, fmt.Errorf("invalid hedging percentile %v: must be in [0, 100]", p)
+    }
+
     return &cfg, nil
 }
+
+// hasRouteTo сообщает, ведёт ли в пул хотя бы одно правило маршрутизации.
+func hasRouteTo(routes []RouteConfig, pool string) bool {
+    for _, route := range routes {
+        if route.Pool == pool {
+            return true
+        }
+    }
+    return false
+}
+
+// applyDefaults заполняет незаданные параметры значениями по умолчанию
+func applyDefaults(cfg *Config) {
+    // backends верхнего уровня образуют пул по умолчанию
+    if len(cfg.Backends) > 0 || len(cfg.Pools) == 0 {
+        if cfg.Pools == nil {
+            cfg.Pools = make(map[string]PoolConfig)
+        }
+        if _, ok := cfg.Pools[DefaultPoolName]; !ok {
+            cfg.Pools[DefaultPoolName] = PoolConfig{Strategy: cfg.Strategy, Backends: cfg.Backends}
+        }
+    }
+    for name, pool := range cfg.Pools {
+        if pool.Strategy == "" {
+            pool.Strategy = cfg.Strategy
+        }
+        if pool.HealthCheck.Type == "" {
+            pool.HealthCheck.Type = "http"
+        }
+        for i := range pool.Backends {
+            if pool.Backends[i].Weight == 0 {
+                pool.Backends[i].Weight = 1
+            }
+        }
+        cfg.Pools[name] = pool
+    }
+    // Пул по умолчанию, если он есть, получает запросы, не подошедшие ни под
+    // одно правило (без таблицы маршрутизации — все): иначе его бэкенды
+    // проверялись бы, но не получали трафика
+    if _, ok := cfg.Pools[DefaultPoolName]; ok && !hasRouteTo(cfg.Routes, DefaultPoolName) {
+        priority := 0
+        for i, route := range cfg.Routes {
+            if i == 0 || route.Priority-1 < priority {
+                priority = route.Priority - 1
+            }
+        }
+        cfg.Routes = append(cfg.Routes, RouteConfig{Name: DefaultPoolName, Pool: DefaultPoolName, PathPrefix: "/", Priority: priority})
+    }
+    if cfg.HealthCheck.Interval <= 0 {
+        cfg.HealthCheck.Interval = 10 * time.Second
+    }
+    if cfg.HealthCheck.Timeout <= 0 {
+        cfg.HealthCheck.Timeout = 2 * time.Second
+    }
+    if cfg.HealthCheck.Path == "" {
+        cfg.HealthCheck.Path = "/healthz"
+    }
+    if cfg.HealthCheck.ExpectedStatus == 0 {
+        cfg.HealthCheck.ExpectedStatus = 200
+    }
+    if cfg.HealthCheck.FallThreshold <= 0 {
+        cfg.HealthCheck.FallThreshold = 3
+    }
+    if cfg.HealthCheck.RiseThreshold <= 0 {
+        cfg.HealthCheck.RiseThreshold = 2
+    }
+    if cfg.HealthCheck.HistorySize <= 0 {
+        cfg.HealthCheck.HistorySize = 10
+    }
+    if cfg.StickySessions.CookieName == "" {
+        cfg.StickySessions.CookieName = "lb_backend"
+    }
+    if cfg.StickySessions.TTL <= 0 {
+        cfg.StickySessions.TTL = time.Hour
+    }
+    if cfg.Retries.Budget.Window <= 0 {
+        cfg.Retries.Budget.Window = 10 * time.Second
+    }
+    if cfg.Transport.MaxIdleConns <= 0 {
+        cfg.Transport.MaxIdleConns = 512
+    }
+    if cfg.Transport.MaxIdleConnsPerHost <= 0 {
+        cfg.Transport.MaxIdleConnsPerHost = 64
+    }
+    if cfg.Transport.IdleConnTimeout <= 0 {
+        cfg.Transport.IdleConnTimeout = 90 * time.Second
+    }
+    if cfg.Transport.DialTimeout <= 0 {
+        cfg.Transport.DialTimeout = 5 * time.Second
+    }
+    if cfg.Transport.KeepAlive <= 0 {
+        cfg.Transport.KeepAlive = 30 * time.Second
+    }
+    if cfg.Transport.TLSHandshakeTimeout <= 0 {
+        cfg.Transport.TLSHandshakeTimeout = 5 * time.Second
+    }
+    if cfg.TLS.Port == 0 {
+        cfg.TLS.Port = 8443
+    }
+    if cfg.TLS.MinVersion == "" {
+        cfg.TLS.MinVersion = "1.2"
+    }
+    if cfg.TLS.ReloadInterval <= 0 {
+        cfg.TLS.ReloadInterval = 30 * time.Second
+    }
+    if cfg.TLS.ClientAuth.Mode == "" {
+        cfg.TLS.ClientAuth.Mode = "none"
+    }
+    if cfg.TLS.ClientAuth.Identity == "" {
+        cfg.TLS.ClientAuth.Identity = "cn"
+    }
+    if cfg.HTTP2.MaxConcurrentStreams == 0 {
+        cfg.HTTP2.MaxConcurrentStreams = 250
+    }
+    if cfg.ProxyProtocol.HeaderTimeout <= 0 {
+        cfg.ProxyProtocol.HeaderTimeout = 5 * time.Second
+    }
+    if cfg.Upgrades.IdleTimeout <= 0 {
+        cfg.Upgrades.IdleTimeout = 10 * time.Minute
+    }
+    if cfg.Hedging.Delay <= 0 {
+        cfg.Hedging.Delay = 50 * time.Millisecond
+    }
+    if cfg.Hedging.Budget.Window <= 0 {
+        cfg.Hedging.Budget.Window = 10 * time.Second
+    }
+    if cfg.OutlierDetection.Consecutive5xx <= 0 {
+        cfg.OutlierDetection.Consecutive5xx = 5
+    }
+    if cfg.OutlierDetection.ConsecutiveGatewayErrors <= 0 {
+        cfg.OutlierDetection.ConsecutiveGatewayErrors = 5
+    }
+    if cfg.OutlierDetection.BaseEjectionTime <= 0 {
+        cfg.OutlierDetection.BaseEjectionTime = 30 * time.Second
+    }
+    if cfg.OutlierDetection.MaxEjectionTime <= 0 {
+        cfg.OutlierDetection.MaxEjectionTime = 300 * time.Second
+    }
+    if cfg.OutlierDetection.MaxEjectionPercent <= 0 {
+        cfg.OutlierDetection.MaxEjectionPercent = 10
+    }
+}
@@ -0,0 +1,150 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v2"
+)
+
+// loadYAML записывает конфигурацию во временный файл и загружает её через Load.
+func loadYAML(t *testing.T, data string) (*Config, error) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return Load(path)
+}
+
+func TestBackendConfigForms(t *testing.T) {
+	tests := []struct {
+		name string
+		yaml string
+		want []BackendConfig
+	}{
+		{
+			name: "strings",
+			yaml: `backends: ["http://a:9001", "http://b:9002"]`,
+			want: []BackendConfig{{URL: "http://a:9001", Weight: 1}, {URL: "http://b:9002", Weight: 1}},
+		},
+		{
+			name: "structs",
+			yaml: "backends:\n  - url: http://a:9001\n    weight: 3\n  - url: http://b:9002\n",
+			want: []BackendConfig{{URL: "http://a:9001", Weight: 3}, {URL: "http://b:9002", Weight: 1}},
+		},
+		{
+			name: "mixed",
+			yaml: "backends:\n  - http://a:9001\n  - url: http://b:9002\n    weight: 2\n",
+			want: []BackendConfig{{URL: "http://a:9001", Weight: 1}, {URL: "http://b:9002", Weight: 2}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var cfg struct {
+				Backends []BackendConfig `yaml:"backends"`
+			}
+			if err := yaml.Unmarshal([]byte(tt.yaml), &cfg); err != nil {
+				t.Fatalf("Unmarshal: %v", err)
+			}
+			if !reflect.DeepEqual(cfg.Backends, tt.want) {
+				t.Fatalf("got %+v, want %+v", cfg.Backends, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadDefaultsBackendWeight(t *testing.T) {
+	cfg, err := loadYAML(t, "backends:\n  - http://a:9001\n  - url: http://b:9002\n")
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	for _, b := range cfg.Pools[DefaultPoolName].Backends {
+		if b.Weight != 1 {
+			t.Errorf("backend %s: expected default weight 1, got %d", b.URL, b.Weight)
+		}
+	}
+}
+
+func TestLoadRejectsNonPositiveWeight(t *testing.T) {
+	for _, weight := range []string{"0", "-1"} {
+		if _, err := loadYAML(t, "backends:\n  - url: http://a:9001\n    weight: "+weight+"\n"); err == nil {
+			t.Errorf("expected weight %s to be rejected", weight)
+		}
+	}
+}
+
+func TestLoadAddsImplicitDefaultRoute(t *testing.T) {
+	cfg, err := loadYAML(t, `backends: ["http://a:9001"]`)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	want := []RouteConfig{{Name: DefaultPoolName, Pool: DefaultPoolName, PathPrefix: "/"}}
+	if !reflect.DeepEqual(cfg.Routes, want) {
+		t.Fatalf("got routes %+v, want %+v", cfg.Routes, want)
+	}
+}
+
+func TestLoadRequiresRoutesWithoutDefaultPool(t *testing.T) {
+	_, err := loadYAML(t, "pools:\n  users:\n    backends: [\"http://users:9101\"]\n")
+	if err == nil || !strings.Contains(err.Error(), "routes are required") {
+		t.Fatalf("expected routes to be required, got %v", err)
+	}
+}
+
+func TestLoadRejectsRouteToUnknownPool(t *testing.T) {
+	_, err := loadYAML(t, `
+pools:
+  users:
+    backends: ["http://users:9101"]
+routes:
+  - name: reports
+    pool: reports
+    path_prefix: /reports
+`)
+	if err == nil || !strings.Contains(err.Error(), `unknown pool "reports"`) {
+		t.Fatalf("expected unknown pool to be rejected, got %v", err)
+	}
+}
+
+func TestLoadRoutesRemainingRequestsToDefaultPool(t *testing.T) {
+	cfg, err := loadYAML(t, `
+backends: ["http://a:9001"]
+pools:
+  users:
+    backends: ["http://users:9101"]
+routes:
+  - name: users-api
+    pool: users
+    path_prefix: /api/users
+    priority: -5
+`)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if len(cfg.Routes) != 2 {
+		t.Fatalf("expected a catch-all route to the default pool, got %+v", cfg.Routes)
+	}
+	catchAll := cfg.Routes[1]
+	if catchAll.Pool != DefaultPoolName || catchAll.PathPrefix != "/" || catchAll.Priority >= -5 {
+		t.Fatalf("expected lowest-priority catch-all to %q, got %+v", DefaultPoolName, catchAll)
+	}
+
+	// Правило в пул по умолчанию задано явно: лишнее не добавляется
+	cfg, err = loadYAML(t, `
+backends: ["http://a:9001"]
+routes:
+  - name: root
+    pool: default
+    path_prefix: /app
+`)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if len(cfg.Routes) != 1 {
+		t.Fatalf("expected explicit routes to be kept as is, got %+v", cfg.Routes)
+	}
+}
@@ -0,0 +1,90 @@
+package grpcstatus
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"google.golang.org/grpc/codes"
+)
+
+// IsGRPC сообщает, является ли запрос вызовом gRPC (application/grpc,
+// application/grpc+proto и т.п.).
+func IsGRPC(r *http.Request) bool {
+	ct := r.Header.Get("Content-Type")
+	return ct == "application/grpc" || strings.HasPrefix(ct, "application/grpc+") || strings.HasPrefix(ct, "application/grpc;")
+}
+
+// SplitMethod разбирает путь вызова gRPC вида /package.Service/Method.
+func SplitMethod(path string) (service, method string, ok bool) {
+	rest, ok := strings.CutPrefix(path, "/")
+	if !ok {
+		return "", "", false
+	}
+	service, method, ok = strings.Cut(rest, "/")
+	if !ok || service == "" || method == "" || strings.Contains(method, "/") {
+		return "", "", false
+	}
+	return service, method, true
+}
+
+// FromHTTP переводит код ответа балансировщика в код gRPC. Отличия от
+// таблицы соответствия gRPC: 429 — RESOURCE_EXHAUSTED (лимит запросов),
+// 504 — DEADLINE_EXCEEDED (бэкенд не ответил вовремя).
+func FromHTTP(status int) codes.Code {
+	switch status {
+	case http.StatusBadRequest:
+		return codes.Internal
+	case http.StatusUnauthorized:
+		return codes.Unauthenticated
+	case http.StatusForbidden:
+		return codes.PermissionDenied
+	case http.StatusNotFound:
+		return codes.Unimplemented
+	case http.StatusTooManyRequests:
+		return codes.ResourceExhausted
+	case http.StatusBadGateway, http.StatusServiceUnavailable:
+		return codes.Unavailable
+	case http.StatusGatewayTimeout:
+		return codes.DeadlineExceeded
+	}
+	return codes.Unknown
+}
+
+// Write отвечает на вызов gRPC ошибкой: HTTP 200 без сообщений и статус в
+// трейлерах grpc-status и grpc-message.
+func Write(w http.ResponseWriter, code codes.Code, msg string) {
+	h := w.Header()
+	h.Del("Content-Length")
+	h.Set("Content-Type", "application/grpc")
+	h.Set("Trailer", "Grpc-Status, Grpc-Message")
+	w.WriteHeader(http.StatusOK)
+	h.Set("Grpc-Status", strconv.Itoa(int(code)))
+	h.Set("Grpc-Message", encodeMessage(msg))
+}
+
+// Error — замена http.Error для ошибок самого балансировщика: вызов gRPC
+// получает статус gRPC в трейлерах, остальные запросы — текст и код HTTP.
+func Error(w http.ResponseWriter, r *http.Request, msg string, status int) {
+	if IsGRPC(r) {
+		Write(w, FromHTTP(status), msg)
+		return
+	}
+	http.Error(w, msg, status)
+}
+
+// encodeMessage кодирует grpc-message: байты вне печатного ASCII и '%'
+// записываются как %XX.
+func encodeMessage(msg string) string {
+	var b strings.Builder
+	for i := 0; i < len(msg); i++ {
+		c := msg[i]
+		if c >= 0x20 && c <= 0x7e && c != '%' {
+			b.WriteByte(c)
+			continue
+		}
+		fmt.Fprintf(&b, "%%%02X", c)
+	}
+	return b.String()
+}
@@ -0,0 +1,89 @@
+package grpcstatus
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+)
+
+func TestSplitMethod(t *testing.T) {
+	cases := []struct {
+		path, service, method string
+		ok                    bool
+	}{
+		{"/grpc.health.v1.Health/Check", "grpc.health.v1.Health", "Check", true},
+		{"/Service/Method", "Service", "Method", true},
+		{"/grpc.health.v1.Health/", "", "", false},
+		{"/grpc.health.v1.Health", "", "", false},
+		{"/a/b/c", "", "", false},
+		{"no-slash/Method", "", "", false},
+	}
+	for _, tc := range cases {
+		service, method, ok := SplitMethod(tc.path)
+		if service != tc.service || method != tc.method || ok != tc.ok {
+			t.Errorf("SplitMethod(%q) = %q, %q, %v", tc.path, service, method, ok)
+		}
+	}
+}
+
+func TestIsGRPC(t *testing.T) {
+	for ct, want := range map[string]bool{
+		"application/grpc":       true,
+		"application/grpc+proto": true,
+		"application/grpc-web":   false,
+		"application/json":       false,
+		"":                       false,
+	} {
+		req := httptest.NewRequest(http.MethodPost, "/", nil)
+		req.Header.Set("Content-Type", ct)
+		if got := IsGRPC(req); got != want {
+			t.Errorf("IsGRPC(%q) = %v, want %v", ct, got, want)
+		}
+	}
+}
+
+func TestErrorWritesTrailers(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "/pkg.Service/Method", nil)
+	req.Header.Set("Content-Type", "application/grpc")
+	rec := httptest.NewRecorder()
+	Error(rec, req, "no available backends: 100%", http.StatusServiceUnavailable)
+
+	resp := rec.Result()
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "application/grpc" {
+		t.Fatalf("Expected 200 application/grpc, got %d %q", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+	if got := resp.Trailer.Get("Grpc-Status"); got != "14" {
+		t.Fatalf("Expected grpc-status 14 (UNAVAILABLE), got %q", got)
+	}
+	if got := resp.Trailer.Get("Grpc-Message"); got != "no available backends: 100%25" {
+		t.Fatalf("Expected percent-encoded message, got %q", got)
+	}
+	if rec.Body.Len() != 0 {
+		t.Fatalf("Expected empty body, got %q", rec.Body.String())
+	}
+}
+
+func TestErrorPlainHTTP(t *testing.T) {
+	rec := httptest.NewRecorder()
+	Error(rec, httptest.NewRequest(http.MethodGet, "/", nil), "no available backends", http.StatusServiceUnavailable)
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("Expected 503 for plain HTTP, got %d", rec.Code)
+	}
+}
+
+func TestFromHTTP(t *testing.T) {
+	for status, want := range map[int]codes.Code{
+		http.StatusTooManyRequests:    codes.ResourceExhausted,
+		http.StatusServiceUnavailable: codes.Unavailable,
+		http.StatusBadGateway:         codes.Unavailable,
+		http.StatusGatewayTimeout:     codes.DeadlineExceeded,
+		http.StatusNotFound:           codes.Unimplemented,
+		http.StatusTeapot:             codes.Unknown,
+	} {
+		if got := FromHTTP(status); got != want {
+			t.Errorf("FromHTTP(%d) = %v, want %v", status, got, want)
+		}
+	}
+}
@@ -0,0 +1,28 @@
+package proxy
+
+import (
+	"net/http"
+
+	"github.com/mk/loadBalancer/internal/grpcstatus"
+)
+
+// streamsGRPC сообщает, снимаются ли с запроса таймауты сервера и общий
+// дедлайн как с потока gRPC. Заголовку Content-Type не доверяем: исключение
+// действует только для пулов, явно настроенных на gRPC, иначе любой клиент
+// обходил бы таймауты заголовком application/grpc.
+func (h *ProxyHandler) streamsGRPC(r *http.Request) bool {
+	return h.GRPC && grpcstatus.IsGRPC(r)
+}
+
+// matchGRPC сообщает, подходит ли вызов gRPC под сервис и метод правила
+// (пустые не проверяются).
+func matchGRPC(r *http.Request, service, method string) bool {
+	if !grpcstatus.IsGRPC(r) {
+		return false
+	}
+	gotService, gotMethod, ok := grpcstatus.SplitMethod(r.URL.Path)
+	if !ok {
+		return false
+	}
+	return (service == "" || service == gotService) && (method == "" || method == gotMethod)
+}
@@ -0,0 +1,259 @@
+package proxy
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// RequestIDHeader — заголовок с идентификатором запроса. Если клиент его не
+// прислал, а правила заголовков используют {request_id}, идентификатор генерируется.
+const RequestIDHeader = "X-Request-Id"
+
+// HeaderOps — изменения заголовков: сначала Remove, затем Set (замена значения),
+// затем Add (добавление ещё одного значения). Значения Set и Add — шаблоны с
+// подстановками {client_ip}, {backend}, {backend_host}, {request_id}, {route},
+// {host}, {method}, {path}.
+type HeaderOps struct {
+	Set    map[string]string
+	Add    map[string]string
+	Remove []string
+}
+
+// HeaderPolicy — правила изменения заголовков запроса к бэкенду и ответа клиенту.
+type HeaderPolicy struct {
+	request  compiledOps
+	response compiledOps
+
+	usesRequestID bool
+}
+
+// NewHeaderPolicy проверяет шаблоны и создаёт HeaderPolicy.
+func NewHeaderPolicy(request, response HeaderOps) (*HeaderPolicy, error) {
+	hp := &HeaderPolicy{}
+	var err error
+	if hp.request, err = compileOps(request); err != nil {
+		return nil, fmt.Errorf("request headers: %w", err)
+	}
+	if hp.response, err = compileOps(response); err != nil {
+		return nil, fmt.Errorf("response headers: %w", err)
+	}
+	hp.usesRequestID = hp.request.uses("request_id") || hp.response.uses("request_id")
+	return hp, nil
+}
+
+// headerVars — значения подстановок для шаблонов заголовков.
+type headerVars struct {
+	clientIP    string
+	backend     string
+	backendHost string
+	requestID   string
+	route       string
+	host        string
+	method      string
+	path        string // путь в закодированном виде: декодированный может содержать CR/LF
+}
+
+func (v *headerVars) lookup(name string) string {
+	switch name {
+	case "client_ip":
+		return v.clientIP
+	case "backend":
+		return v.backend
+	case "backend_host":
+		return v.backendHost
+	case "request_id":
+		return v.requestID
+	case "route":
+		return v.route
+	case "host":
+		return v.host
+	case "method":
+		return v.method
+	case "path":
+		return v.path
+	}
+	return ""
+}
+
+var headerVarNames = map[string]bool{
+	"client_ip": true, "backend": true, "backend_host": true, "request_id": true,
+	"route": true, "host": true, "method": true, "path": true,
+}
+
+// headerTemplate — значение заголовка, разобранное на текст и подстановки.
+type headerTemplate []templatePart
+
+type templatePart struct {
+	text     string
+	variable string // имя подстановки; пусто — part.text выводится как есть
+}
+
+func parseHeaderTemplate(s string) (headerTemplate, error) {
+	var t headerTemplate
+	for s != "" {
+		start := strings.IndexByte(s, '{')
+		if start < 0 {
+			t = append(t, templatePart{text: s})
+			break
+		}
+		end := strings.IndexByte(s[start:], '}')
+		if end < 0 {
+			return nil, fmt.Errorf("unclosed '{' in %q", s)
+		}
+		name := s[start+1 : start+end]
+		if !headerVarNames[name] {
+			return nil, fmt.Errorf("unknown variable {%s}", name)
+		}
+		if start > 0 {
+			t = append(t, templatePart{text: s[:start]})
+		}
+		t = append(t, templatePart{variable: name})
+		s = s[start+end+1:]
+	}
+	return t, nil
+}
+
+func (t headerTemplate) render(v *headerVars) string {
+	if len(t) == 1 && t[0].variable == "" {
+		return t[0].text
+	}
+	var b strings.Builder
+	for _, part := range t {
+		if part.variable != "" {
+			b.WriteString(stripControl(v.lookup(part.variable)))
+		} else {
+			b.WriteString(part.text)
+		}
+	}
+	return b.String()
+}
+
+// stripControl удаляет из подставляемого значения управляющие символы (кроме
+// табуляции): транспорт отклоняет заголовок с ними, и запрос клиента падал бы
+// ошибкой, засчитанной бэкенду.
+func stripControl(s string) string {
+	if strings.IndexFunc(s, isControl) < 0 {
+		return s
+	}
+	return strings.Map(func(r rune) rune {
+		if isControl(r) {
+			return -1
+		}
+		return r
+	}, s)
+}
+
+func isControl(r rune) bool {
+	return (r < 0x20 && r != '\t') || r == 0x7f
+}
+
+type headerValue struct {
+	name  string
+	value headerTemplate
+}
+
+type compiledOps struct {
+	remove []string
+	set    []headerValue
+	add    []headerValue
+}
+
+func compileOps(ops HeaderOps) (compiledOps, error) {
+	var c compiledOps
+	for _, name := range ops.Remove {
+		c.remove = append(c.remove, http.CanonicalHeaderKey(name))
+	}
+	var err error
+	if c.set, err = compileValues(ops.Set); err != nil {
+		return c, err
+	}
+	if c.add, err = compileValues(ops.Add); err != nil {
+		return c, err
+	}
+	return c, nil
+}
+
+func compileValues(values map[string]string) ([]headerValue, error) {
+	out := make([]headerValue, 0, len(values))
+	for name, value := range values {
+		t, err := parseHeaderTemplate(value)
+		if err != nil {
+			return nil, fmt.Errorf("header %s: %w", name, err)
+		}
+		out = append(out, headerValue{name: http.CanonicalHeaderKey(name), value: t})
+	}
+	return out, nil
+}
+
+func (c compiledOps) uses(variable string) bool {
+	for _, list := range [][]headerValue{c.set, c.add} {
+		for _, hv := range list {
+			for _, part := range hv.value {
+				if part.variable == variable {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+func (c compiledOps) apply(h http.Header, v *headerVars) {
+	for _, name := range c.remove {
+		h.Del(name)
+	}
+	for _, hv := range c.set {
+		h.Set(hv.name, hv.value.render(v))
+	}
+	for _, hv := range c.add {
+		h.Add(hv.name, hv.value.render(v))
+	}
+}
+
+// headerPolicies возвращает правила пула и маршрута в порядке применения:
+// правила маршрута применяются последними и могут переопределить правила пула.
+func (h *ProxyHandler) headerPolicies(r *http.Request) []*HeaderPolicy {
+	var policies []*HeaderPolicy
+	if h.Headers != nil {
+		policies = append(policies, h.Headers)
+	}
+	if rt := routeFrom(r); rt != nil && rt.HeaderPolicy != nil {
+		policies = append(policies, rt.HeaderPolicy)
+	}
+	return policies
+}
+
+// requestID возвращает идентификатор запроса, если он нужен правилам заголовков:
+// присланный клиентом или новый.
+func requestID(r *http.Request, policies []*HeaderPolicy) string {
+	for _, hp := range policies {
+		if hp.usesRequestID {
+			if id := r.Header.Get(RequestIDHeader); id != "" {
+				return id
+			}
+			return newRequestID()
+		}
+	}
+	return ""
+}
+
+// requestIDSeq различает идентификаторы, созданные без crypto/rand.
+var requestIDSeq atomic.Uint64
+
+// newRequestID возвращает случайный 128-битный идентификатор. Если источник
+// случайности недоступен, идентификатор строится из времени и счётчика,
+// чтобы запросы не получили одинаковый ID.
+func newRequestID() string {
+	var buf [16]byte
+	if _, err := rand.Read(buf[:]); err != nil {
+		binary.BigEndian.PutUint64(buf[:8], uint64(time.Now().UnixNano()))
+		binary.BigEndian.PutUint64(buf[8:], requestIDSeq.Add(1))
+	}
+	return hex.EncodeToString(buf[:])
+}
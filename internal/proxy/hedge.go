@@ -0,0 +1,328 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mk/loadBalancer/internal/balancer"
+)
+
+const (
+	// hedgeSamples — сколько последних задержек хранится для расчёта перцентиля.
+	hedgeSamples = 1000
+	// hedgeMinSamples — минимум замеров, после которого задержка берётся из перцентиля.
+	hedgeMinSamples = 20
+)
+
+// Hedger отправляет для чувствительных к задержке маршрутов второй (хеджирующий)
+// запрос на другой бэкенд, если первый не прислал заголовки ответа за Delay или
+// за задержку, равную Percentile-му перцентилю наблюдаемых задержек. Клиент
+// получает первый ответ, второй запрос отменяется. Хеджирование расходует Budget,
+// чтобы не удваивать нагрузку на бэкенды.
+type Hedger struct {
+	Paths      []string              // префиксы путей, для которых включено хеджирование
+	Delay      time.Duration         // фиксированная задержка (и задержка, пока мало замеров)
+	Percentile float64               // перцентиль задержки (0 — только фиксированная задержка)
+	Budget     *balancer.RetryBudget // бюджет хеджирующих запросов (nil — без ограничений)
+
+	mu      sync.Mutex
+	samples []time.Duration // кольцевой буфер последних задержек
+	next    int
+}
+
+// NewHedger создаёт Hedger для заданных путей.
+func NewHedger(paths []string, delay time.Duration, percentile float64, budget *balancer.RetryBudget) *Hedger {
+	return &Hedger{
+		Paths:      paths,
+		Delay:      delay,
+		Percentile: percentile,
+		Budget:     budget,
+		samples:    make([]time.Duration, 0, hedgeSamples),
+	}
+}
+
+// applies сообщает, хеджируется ли запрос: только GET и HEAD на настроенных путях.
+func (hg *Hedger) applies(r *http.Request) bool {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return false
+	}
+	// Тело нельзя отправить двум бэкендам одновременно, а туннель — открыть дважды
+	if r.ContentLength != 0 || isUpgrade(r) {
+		return false
+	}
+	for _, prefix := range hg.Paths {
+		if strings.HasPrefix(r.URL.Path, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// delay возвращает задержку перед хеджирующим запросом.
+func (hg *Hedger) delay() time.Duration {
+	if hg.Percentile <= 0 {
+		return hg.Delay
+	}
+
+	hg.mu.Lock()
+	if len(hg.samples) < hedgeMinSamples {
+		hg.mu.Unlock()
+		return hg.Delay
+	}
+	sorted := append([]time.Duration(nil), hg.samples...)
+	hg.mu.Unlock()
+
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	idx := int(hg.Percentile / 100 * float64(len(sorted)-1))
+	return sorted[idx]
+}
+
+// observe запоминает время до получения заголовков ответа.
+func (hg *Hedger) observe(latency time.Duration) {
+	hg.mu.Lock()
+	defer hg.mu.Unlock()
+	if len(hg.samples) < hedgeSamples {
+		hg.samples = append(hg.samples, latency)
+		return
+	}
+	hg.samples[hg.next] = latency
+	hg.next = (hg.next + 1) % hedgeSamples
+}
+
+// allow расходует хеджирующий запрос из бюджета.
+func (hg *Hedger) allow() bool {
+	return hg.Budget == nil || hg.Budget.TryRetry()
+}
+
+// recordRequest учитывает хеджируемый запрос в бюджете.
+func (hg *Hedger) recordRequest() {
+	if hg.Budget != nil {
+		hg.Budget.RecordRequest()
+	}
+}
+
+// hedgingTransport — RoundTripper, который при задержке ответа основного бэкенда
+// дублирует запрос на второй бэкенд и возвращает первый полученный ответ.
+type hedgingTransport struct {
+	base    http.RoundTripper
+	hedger  *Hedger
+	pool    *balancer.ServerPool
+	sc      *balancer.SelectionContext
+	primary *balancer.Backend
+
+	prepared *http.Request // запрос к бэкенду до привязки к бэкенду попытки (см. rewrite)
+
+	primaryHandled bool              // итог основного запроса уже учтён транспортом (или он отменён)
+	failedHedge    *balancer.Backend // хеджирующий бэкенд, на котором запрос упал (nil — нет)
+}
+
+type hedgeResult struct {
+	resp    *http.Response
+	err     error
+	hedge   bool
+	backend *balancer.Backend
+	latency time.Duration
+	lost    bool // запрос отменён после ответа другого
+	aborted bool // запрос прерван уходом клиента
+}
+
+// RoundTrip отправляет запрос на основной бэкенд и, если заголовки ответа не
+// пришли за задержку хеджирования, — на второй.
+func (t *hedgingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.hedger.recordRequest()
+	results := make(chan hedgeResult, 2)
+
+	cancels := []func(){t.launch(req, nil, false, results)}
+
+	timer := time.NewTimer(t.hedger.delay())
+	defer timer.Stop()
+
+	var first hedgeResult
+	var hedgeAttempt *attempt
+	select {
+	case first = <-results:
+	case <-timer.C:
+		if hedge := t.pickHedge(); hedge != nil {
+			var out *http.Request
+			if out, hedgeAttempt = t.hedgeRequest(req, hedge); out != nil {
+				cancels = append(cancels, t.launch(out, hedge, true, results))
+			} else {
+				// Бэкенд выбран, но запрос не отправлен: пробный запрос выключателя возвращается
+				t.pool.Done(hedge, balancer.OutcomeCancelled, 0)
+			}
+		}
+		first = <-results
+	}
+	pending := len(cancels) - 1
+
+	// Если первый ответ — ошибка, ждём второй запрос, а ошибку учитываем сразу
+	if first.err != nil && pending > 0 {
+		t.done(first)
+		first = <-results
+		pending--
+	} else if pending > 0 {
+		// Проигравший запрос отменяется, его ответ (если успел прийти) закрывается.
+		// Итог проигравшего тоже передаётся пулу: иначе выключатель не вернёт
+		// пробный запрос, а EWMA и детектор выбросов не увидят его результата
+		cancels[1-index(first.hedge)]()
+		if first.hedge {
+			t.primaryHandled = true
+		}
+		go func() {
+			loser := <-results
+			if loser.resp != nil {
+				loser.resp.Body.Close()
+			}
+			loser.lost = true
+			t.report(loser)
+		}()
+	}
+
+	if !first.hedge {
+		t.observePrimary(first)
+	}
+	if first.hedge {
+		// Ответил хеджирующий бэкенд: шаблоны заголовков ответа описывают его
+		if at := attemptFrom(req); at.vars != nil {
+			at.vars = hedgeAttempt.vars
+		}
+		t.done(first)
+	}
+	return first.resp, first.err
+}
+
+// done передаёт пулу результат запроса, завершённого внутри транспорта.
+func (t *hedgingTransport) done(res hedgeResult) {
+	if !res.hedge {
+		// Итог основного запроса учтён здесь: forward его уже не учитывает
+		t.primaryHandled = true
+	} else if res.err != nil && !res.aborted {
+		// Повтор не должен выбрать бэкенд, на котором запрос уже упал
+		t.failedHedge = res.backend
+	}
+	t.report(res)
+}
+
+// report передаёт пулу итог запроса. Запрос, отменённый после ответа другого
+// или из-за ухода клиента, учитывается как OutcomeCancelled; отмена по
+// таймауту попытки или общему дедлайну — ошибка бэкенда.
+func (t *hedgingTransport) report(res hedgeResult) {
+	t.observePrimary(res)
+	backend := res.backend
+	if !res.hedge {
+		backend = t.primary
+	}
+	switch {
+	case res.lost && errors.Is(res.err, context.Canceled), res.aborted:
+		t.pool.Done(backend, balancer.OutcomeCancelled, res.latency)
+	case res.err != nil:
+		t.pool.Done(backend, balancer.OutcomeError, res.latency)
+	default:
+		t.pool.Done(backend, balancer.OutcomeFromStatus(res.resp.StatusCode), res.latency)
+	}
+}
+
+// observePrimary учитывает в выборке задержки хеджирования время до заголовков
+// ответа основного бэкенда. Основной запрос, отменённый после ответа
+// хеджирующего, учитывается временем до отмены (настоящее время не меньше):
+// иначе медленные ответы выпадали бы из выборки, задержка сползала бы вниз и
+// хеджирующих запросов становилось бы всё больше.
+func (t *hedgingTransport) observePrimary(res hedgeResult) {
+	if res.hedge || (res.err != nil && !res.lost) {
+		return
+	}
+	t.hedger.observe(res.latency)
+}
+
+// index возвращает номер запроса: 0 — основной, 1 — хеджирующий.
+func index(hedge bool) int {
+	if hedge {
+		return 1
+	}
+	return 0
+}
+
+// pickHedge выбирает бэкенд для хеджирующего запроса с учётом бюджета.
+// Основной бэкенд исключается только для этого выбора: список исключений
+// запроса принадлежит циклу повторов.
+func (t *hedgingTransport) pickHedge() *balancer.Backend {
+	if !t.hedger.allow() {
+		return nil
+	}
+	sc := t.sc.Clone()
+	sc.Exclude(t.primary)
+	return t.pool.Select(sc)
+}
+
+// hedgeRequest строит запрос к хеджирующему бэкенду так же, как rewrite строит
+// основной: из подготовленного запроса, с базовым путём и шаблонами заголовков
+// этого бэкенда. Возвращает nil, если URL бэкенда не разбирается.
+func (t *hedgingTransport) hedgeRequest(req *http.Request, backend *balancer.Backend) (*http.Request, *attempt) {
+	target, err := url.Parse(backend.URL)
+	if err != nil || t.prepared == nil {
+		return nil, nil
+	}
+	at := attemptFrom(req).forBackend(backend, target)
+	pr := &httputil.ProxyRequest{Out: t.prepared.Clone(req.Context())}
+	at.direct(pr)
+	// Как и ReverseProxy, не отправляем User-Agent клиента Go по умолчанию
+	if _, ok := pr.Out.Header["User-Agent"]; !ok {
+		pr.Out.Header.Set("User-Agent", "")
+	}
+	return pr.Out, at
+}
+
+// launch отправляет копию запроса в отдельной горутине. Возвращает функцию,
+// отменяющую запрос; она же вызывается при закрытии тела ответа.
+func (t *hedgingTransport) launch(req *http.Request, backend *balancer.Backend, hedge bool, results chan<- hedgeResult) func() {
+	ctx, cancel := context.WithCancel(req.Context())
+	out := req.Clone(ctx)
+	if backend != nil {
+		backend.IncConnections()
+	}
+
+	// release идемпотентна: её вызывают и закрытие тела ответа, и отмена проигравшего запроса
+	var once sync.Once
+	release := func() {
+		once.Do(func() {
+			cancel()
+			if backend != nil {
+				backend.DecConnections()
+			}
+		})
+	}
+
+	go func() {
+		start := time.Now()
+		resp, err := t.base.RoundTrip(out)
+		if err != nil {
+			release()
+			aborted := attemptFrom(req).clientGone(req, err)
+			results <- hedgeResult{err: err, hedge: hedge, backend: backend, latency: time.Since(start), aborted: aborted}
+			return
+		}
+		resp.Body = &releasingBody{ReadCloser: resp.Body, release: release}
+		results <- hedgeResult{resp: resp, hedge: hedge, backend: backend, latency: time.Since(start)}
+	}()
+	return release
+}
+
+// releasingBody освобождает ресурсы запроса при закрытии тела ответа.
+type releasingBody struct {
+	io.ReadCloser
+	release func()
+}
+
+func (b *releasingBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.release()
+	return err
+}
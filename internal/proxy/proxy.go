@@ -1,7 +1,7 @@
 // Пакет proxy реализует HTTP-прокси-обработчик, который:
 // 1. Получает следующий доступный backend из ServerPool (с учетом алгоритма балансировки).
 // 2. Проксирует запрос к выбранному backend-серверу.
-// 3. Прокидывает IP клиента через X-Real-IP и X-Forwarded-For.
+// 3. Прокидывает IP клиента через X-Real-IP, X-Forwarded-For и Forwarded.
 // 4. Обрабатывает ошибки при недоступности backend'ов и уменьшает активные подключения.
 //
 // 
@@ -10,13 +10,24 @@
 package proxy
 
 import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
 	"net"
 	"net/http"
 	"net/http/httputil"
 	"net/url"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/mk/loadBalancer/internal/balancer"        // Пакет с реализацией пулов backend'ов и логики балансировки
+	"github.com/mk/loadBalancer/internal/certs"           // Поля клиентских сертификатов
+	"github.com/mk/loadBalancer/internal/clientip"        // Определение IP клиента за доверенными прокси
+	"github.com/mk/loadBalancer/internal/grpcstatus"      // Ошибки балансировщика для вызовов gRPC
 	"github.com/mk/loadBalancer/internal/ratelimiter"     // Пакет с middleware и логикой ограничения скорости
 	"go.uber.org/zap"
 )
@@ -26,6 +37,18 @@ type ProxyHandler struct {
     BackendPool   *balancer.ServerPool          // Пул backend-серверов с балансировкой нагрузки
     RateLimiter   *ratelimiter.RateLimiter      // Rate Limiter (не используется напрямую, так как подключается как middleware)
     Logger        *zap.SugaredLogger            // Логгер
+    Sticky        *StickySessions               // Привязка клиента к бэкенду через cookie (nil — выключена)
+    Retry         RetryPolicy                   // Повторы идемпотентных запросов на другом бэкенде
+    Hedging       *Hedger                       // Хеджирование медленных запросов (nil — выключено)
+    Transport     http.RoundTripper             // Транспорт до бэкендов (nil — http.DefaultTransport)
+    Headers       *HeaderPolicy                 // Правила заголовков пула (nil — без изменений)
+    ClientIP      *clientip.Resolver            // Определение IP клиента за доверенными прокси (nil — только адрес соединения)
+    Tunnels       *Tunnels                      // Учёт и ограничения WebSocket/Upgrade-соединений (nil — без ограничений)
+    CertHeaders   map[string]string             // Заголовок -> поле проверенного клиентского сертификата (см. certs.Field)
+    GRPC          bool                          // Пул настроен на gRPC (protocol http2/h2c): потоки gRPC живут дольше таймаутов
+
+    proxyOnce sync.Once
+    proxy     *httputil.ReverseProxy // общий для всех запросов, создаётся при первом запросе
 }
 
 // NewProxyHandler — конструктор ProxyHandler
@@ -34,6 +57,7 @@ func NewProxyHandler(pool *balancer.ServerPool, limiter *ratelimiter.RateLimiter
         BackendPool: pool,
         RateLimiter: limiter,
         Logger:      logger,
+        Transport:   NewTransport(DefaultTransportConfig()),
     }
 }
 
@@ -43,6 +67,7 @@ func NewProxyHandler(pool *balancer.ServerPool, limiter *ratelimiter.RateLimiter
 // - выбирает backend
 // - создает reverse proxy
 // - прокидывает IP клиента
+// - повторяет идемпотентные запросы на другом backend'е при транспортных ошибках
 // - логирует и управляет соединениями
 func (h *ProxyHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	if r.URL.Path == "/favicon.ico" {
@@ -50,57 +75,465 @@ func (h *ProxyHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	
-	clientIP := getClientIP(r) // Извлекаем IP клиента для логирования и прокидывания
+	clientIP := h.clientIPResolver().ClientIP(r) // IP клиента для логирования и прокидывания
 
-	backend := h.BackendPool.NextBackend()
-	if backend == nil {
-		http.Error(w, "no available backends", http.StatusServiceUnavailable)
-		return
+	// Тело идемпотентного запроса буферизуется, чтобы его можно было отправить повторно
+	attempts := h.Retry.attempts(r)
+	var body []byte
+	if attempts > 1 {
+		buf, rest, ok, err := replayableBody(r, h.Retry.bodyLimit())
+		if err != nil {
+			grpcstatus.Error(w, r, "failed to read request body", http.StatusBadRequest)
+			return
+		}
+		if ok {
+			body = buf
+		} else {
+			attempts = 1
+			r.Body = rest
+		}
+	}
+
+	// Общий дедлайн не действует на вызовы gRPC к пулу, настроенному на gRPC.
+	// С запроса на смену протокола он снимается, только когда бэкенд ответил
+	// 101 (см. switchProtocols): время жизни туннеля ограничивают Tunnels
+	ctx, cancel := context.WithCancelCause(r.Context())
+	defer cancel(nil)
+	var total *time.Timer
+	if h.Retry.TotalTimeout > 0 && !h.streamsGRPC(r) {
+		total = time.AfterFunc(h.Retry.TotalTimeout, func() { cancel(context.DeadlineExceeded) })
+		defer total.Stop()
 	}
 
-	targetURL, _ := url.Parse(backend.URL)
-	proxy := httputil.NewSingleHostReverseProxy(targetURL)
+	h.BackendPool.RecordRequest()
 
-	// Переопределяем поведение director для кастомизации запроса
-	originalDirector := proxy.Director
-	proxy.Director = func(req *http.Request) {
-		originalDirector(req)
+	// Идентификатор запроса общий для всех попыток
+	if id := requestID(r, h.headerPolicies(r)); id != "" {
+		ctx = context.WithValue(ctx, requestIDKey{}, id)
+	}
 
-		// Устанавливаем Host для backend-сервера
-		req.Host = targetURL.Host
+	sc := balancer.NewSelectionContext(r, clientIP)
+	var lastErr error
+	for attempt := 1; ; attempt++ {
+		backend := h.pickBackend(w, r, sc, attempt)
+		if backend == nil {
+			// Для повтора не осталось бэкендов: клиент получает последнюю ошибку
+			if lastErr != nil {
+				writeProxyError(w, r, lastErr)
+				return
+			}
+			grpcstatus.Error(w, r, "no available backends", http.StatusServiceUnavailable)
+			return
+		}
 
-		// Прокидываем X-Real-IP
-		req.Header.Set("X-Real-IP", clientIP)
+		req := r.WithContext(ctx)
+		if body != nil {
+			req.Body = io.NopCloser(bytes.NewReader(body))
+			req.GetBody = func() (io.ReadCloser, error) { return io.NopCloser(bytes.NewReader(body)), nil }
+		}
 
-		// Прокидываем X-Forwarded-For (дополняем цепочку)
-		prior := req.Header.Get("X-Forwarded-For")
-		if prior != "" {
-			req.Header.Set("X-Forwarded-For", prior+", "+clientIP)
-		} else {
-			req.Header.Set("X-Forwarded-For", clientIP)
+		// Клиент и логи видят, был ли ответ получен с повтора
+		w.Header().Set(RetryCountHeader, strconv.Itoa(attempt-1))
+
+		err := h.forward(w, req, sc, backend, attempt, total)
+		if err == nil {
+			return
+		}
+
+		// Бэкенд, на котором запрос уже упал, больше не выбирается для него
+		sc.Exclude(backend)
+		lastErr = err
+		if attempt >= attempts || ctx.Err() != nil {
+			writeProxyError(w, r, err)
+			return
 		}
+		// Бюджет повторов исчерпан: отвечаем сразу, не нагружая остальные бэкенды
+		if !h.BackendPool.TryRetry() {
+			h.Logger.Warnw("retry budget exhausted, failing fast",
+				"attempt", attempt, "failed_backend", backend.URL, "error", err)
+			writeProxyError(w, r, err)
+			return
+		}
+		h.Logger.Warnw("retrying request on another backend",
+			"attempt", attempt+1, "failed_backend", backend.URL, "error", err)
 	}
+}
 
-	// Обработка ошибок при проксировании
-	proxy.ErrorHandler = func(w http.ResponseWriter, r *http.Request, err error) {
-		h.Logger.Warnf("proxy error: %v", err)
+// RetryCountHeader — заголовок ответа с числом повторов, понадобившихся для ответа (0 — с первой попытки).
+const RetryCountHeader = "X-Retry-Count"
 
-		// Обработка критичных ошибок
-		if isCriticalError(err) {
-			http.Error(w, "Service unavailable due to backend error", http.StatusServiceUnavailable)
-		} else {
-			http.Error(w, "Backend error", http.StatusBadGateway)
+// errPerTryTimeout — попытка не получила заголовки ответа за PerTryTimeout.
+var errPerTryTimeout = errors.New("per-try timeout exceeded")
+
+// attempt — состояние одной попытки проксирования. Передаётся общему
+// ReverseProxy через контекст запроса.
+type attempt struct {
+	target     *url.URL
+	backend    *balancer.Backend
+	clientIP   string
+	remoteAddr string // адрес собеседника балансировщика
+	hedging    *hedgingTransport
+	headers    []*HeaderPolicy
+	vars       *headerVars
+	timer      *time.Timer
+	total      *time.Timer         // общий дедлайн запроса (nil — без дедлайна)
+	w          http.ResponseWriter // ответ клиенту, с соединения которого снимаются таймауты
+	timedOut   atomic.Bool
+	start      time.Time
+
+	outcome balancer.Outcome
+	latency time.Duration
+	err     error
+}
+
+type attemptKey struct{}
+
+type requestIDKey struct{}
+
+// clientAddr возвращает адрес клиента для PROXY protocol. Порт известен,
+// только если клиент — непосредственный собеседник балансировщика.
+func (at *attempt) clientAddr() net.Addr {
+	ip := net.ParseIP(at.clientIP)
+	if ip == nil {
+		return nil
+	}
+	addr := &net.TCPAddr{IP: ip}
+	if host, port, err := net.SplitHostPort(at.remoteAddr); err == nil && host == at.clientIP {
+		addr.Port, _ = strconv.Atoi(port)
+	}
+	return addr
+}
+
+func attemptFrom(r *http.Request) *attempt {
+	return r.Context().Value(attemptKey{}).(*attempt)
+}
+
+// reverseProxy возвращает общий для всех запросов ReverseProxy. Бэкенд и
+// параметры попытки он берёт из контекста запроса. Ответы без Content-Length
+// (потоки gRPC, chunked) ReverseProxy отдаёт клиенту после каждой записи, не
+// буферизуя; трейлеры ответа передаются клиенту после тела.
+func (h *ProxyHandler) reverseProxy() *httputil.ReverseProxy {
+	h.proxyOnce.Do(func() {
+		h.proxy = &httputil.ReverseProxy{
+			Rewrite:        h.rewrite,
+			Transport:      attemptTransport{h},
+			ModifyResponse: h.modifyResponse,
+			ErrorHandler:   h.handleError,
+			BufferPool:     newBufferPool(32 * 1024),
+		}
+	})
+	return h.proxy
+}
+
+// rewrite направляет запрос на бэкенд попытки и прокидывает IP клиента.
+func (h *ProxyHandler) rewrite(pr *httputil.ProxyRequest) {
+	at := attemptFrom(pr.In)
+
+	// Путь меняется по правилу маршрута до склейки с базовым путём бэкенда
+	if rt := routeFrom(pr.In); rt != nil && rt.Rewrite != nil {
+		pr.Out.URL.Path = rt.Rewrite.Apply(pr.Out.URL.Path)
+		pr.Out.URL.RawPath = ""
+	}
+
+	// Прокидываем X-Real-IP
+	pr.Out.Header.Set("X-Real-IP", at.clientIP)
+
+	// Цепочкам от доверенного прокси дописываем адрес собеседника, остальные отбрасываем
+	peer := clientip.PeerIP(pr.In)
+	trusted := h.clientIPResolver().IsTrusted(peer)
+	proto := "http"
+	if pr.In.TLS != nil {
+		proto = "https"
+	}
+
+	// X-Forwarded-For
+	xff := peer
+	if prior := pr.In.Header.Values("X-Forwarded-For"); trusted && len(prior) > 0 {
+		xff = strings.Join(prior, ", ") + ", " + peer
+	}
+	pr.Out.Header.Set("X-Forwarded-For", xff)
+
+	// Forwarded (RFC 7239): элемент этого прокси с полученными им Host и протоколом
+	forwarded := "for=" + clientip.FormatNode(peer) + ";host=" + strconv.Quote(pr.In.Host) + ";proto=" + proto
+	if prior := pr.In.Header.Values("Forwarded"); trusted && len(prior) > 0 {
+		forwarded = strings.Join(prior, ", ") + ", " + forwarded
+	}
+	pr.Out.Header.Set("Forwarded", forwarded)
+
+	// X-Forwarded-Proto и X-Forwarded-Host описывают исходный запрос клиента
+	xfProto, xfHost := proto, pr.In.Host
+	if trusted {
+		if v := pr.In.Header.Get("X-Forwarded-Proto"); v != "" {
+			xfProto = v
+		}
+		if v := pr.In.Header.Get("X-Forwarded-Host"); v != "" {
+			xfHost = v
+		}
+	}
+	pr.Out.Header.Set("X-Forwarded-Proto", xfProto)
+	pr.Out.Header.Set("X-Forwarded-Host", xfHost)
+
+	// Данные проверенного клиентского сертификата; значения, присланные клиентом, отбрасываются
+	if len(h.CertHeaders) > 0 {
+		cert := certs.VerifiedClientCert(pr.In)
+		for name, field := range h.CertHeaders {
+			pr.Out.Header.Del(name)
+			if cert == nil {
+				continue
+			}
+			if v := certs.Field(cert, field); v != "" {
+				pr.Out.Header.Set(name, v)
+			}
+		}
+	}
+
+	// Хеджирующий запрос строится из этого же состояния для своего бэкенда
+	if at.hedging != nil {
+		at.hedging.prepared = pr.Out.Clone(pr.Out.Context())
+	}
+	at.direct(pr)
+}
+
+// direct направляет подготовленный запрос на бэкенд попытки. SetURL склеивает
+// путь с базовым путём бэкенда (http://svc:9000/v2) и устанавливает Host
+// бэкенда; правила заголовков применяются последними и могут переопределить
+// заголовки, выставленные rewrite.
+func (at *attempt) direct(pr *httputil.ProxyRequest) {
+	pr.SetURL(at.target)
+	for _, hp := range at.headers {
+		hp.request.apply(pr.Out.Header, at.vars)
+	}
+}
+
+// forBackend возвращает параметры попытки для другого бэкенда: шаблоны
+// заголовков ({backend}, {backend_host}) описывают его.
+func (at *attempt) forBackend(backend *balancer.Backend, target *url.URL) *attempt {
+	other := &attempt{
+		target:     target,
+		backend:    backend,
+		clientIP:   at.clientIP,
+		remoteAddr: at.remoteAddr,
+		headers:    at.headers,
+	}
+	if at.vars != nil {
+		vars := *at.vars
+		vars.backend, vars.backendHost = backend.URL, target.Host
+		other.vars = &vars
+	}
+	return other
+}
+
+// modifyResponse фиксирует результат запроса и время до получения заголовков ответа.
+func (h *ProxyHandler) modifyResponse(resp *http.Response) error {
+	at := attemptFrom(resp.Request)
+	if at.timer != nil {
+		at.timer.Stop()
+	}
+	at.latency = time.Since(at.start)
+	at.outcome = balancer.OutcomeFromStatus(resp.StatusCode)
+	for _, hp := range at.headers {
+		hp.response.apply(resp.Header, at.vars)
+	}
+	// После смены протокола соединение с бэкендом становится туннелем
+	if resp.StatusCode == http.StatusSwitchingProtocols {
+		at.switchProtocols()
+		if h.Tunnels != nil {
+			if conn, ok := resp.Body.(io.ReadWriteCloser); ok {
+				resp.Body = h.Tunnels.track(conn, at.backend)
+			}
+		}
+	}
+	return nil
+}
+
+// switchProtocols снимает с запроса, для которого бэкенд согласился сменить
+// протокол, общий дедлайн и таймауты http.Server: туннель живёт дольше них.
+// До ответа 101 запрос на смену протокола ограничен как обычный, иначе
+// заголовка Upgrade хватило бы, чтобы обойти таймауты.
+func (at *attempt) switchProtocols() {
+	if at.total != nil {
+		at.total.Stop()
+	}
+	rc := http.NewResponseController(at.w)
+	rc.SetReadDeadline(time.Time{})
+	rc.SetWriteDeadline(time.Time{})
+}
+
+// handleError запоминает ошибку проксирования: ответ пишет вызывающий код.
+func (h *ProxyHandler) handleError(w http.ResponseWriter, r *http.Request, err error) {
+	h.Logger.Warnf("proxy error: %v", err)
+
+	// Бэкенд не помечается мёртвым: транспортная ошибка учитывается
+	// детектором выбросов через BackendPool.Done, а статус Alive
+	// определяют активные health checks.
+	at := attemptFrom(r)
+	at.outcome = balancer.OutcomeError
+	if at.timedOut.Load() {
+		err = errPerTryTimeout
+	} else if cause := context.Cause(r.Context()); errors.Is(cause, context.DeadlineExceeded) {
+		// Истёк общий дедлайн запроса
+		err = cause
+	} else if at.clientGone(r, err) {
+		// Клиент ушёл, не дождавшись ответа: бэкенд в этом не виноват
+		at.outcome = balancer.OutcomeCancelled
+	}
+	at.err = err
+}
+
+// clientGone сообщает, что запрос прерван уходом клиента, а не таймаутом
+// попытки или общим дедлайном балансировщика.
+func (at *attempt) clientGone(r *http.Request, err error) bool {
+	return errors.Is(err, context.Canceled) && r.Context().Err() != nil &&
+		!at.timedOut.Load() && !errors.Is(context.Cause(r.Context()), context.DeadlineExceeded)
+}
+
+// attemptTransport отправляет запрос через общий транспорт или, если попытка
+// хеджируется, через транспорт хеджирования.
+type attemptTransport struct {
+	h *ProxyHandler
+}
+
+func (t attemptTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	if at := attemptFrom(r); at.hedging != nil {
+		return at.hedging.RoundTrip(r)
+	}
+	return t.h.transport().RoundTrip(r)
+}
+
+// transport возвращает транспорт до бэкендов.
+func (h *ProxyHandler) transport() http.RoundTripper {
+	if h.Transport != nil {
+		return h.Transport
+	}
+	return http.DefaultTransport
+}
+
+// forward проксирует запрос на backend. Если запрос завершился транспортной
+// ошибкой, ответ клиенту не пишется и ошибка возвращается, чтобы запрос можно
+// было повторить на другом backend'е.
+func (h *ProxyHandler) forward(w http.ResponseWriter, r *http.Request, sc *balancer.SelectionContext, backend *balancer.Backend, try int, total *time.Timer) error {
+	targetURL, err := url.Parse(backend.URL)
+	if err != nil {
+		return err
+	}
+	at := &attempt{
+		target:     targetURL,
+		backend:    backend,
+		clientIP:   sc.ClientIP,
+		remoteAddr: r.RemoteAddr,
+		outcome:    balancer.OutcomeSuccess,
+		headers:    h.headerPolicies(r),
+		total:      total,
+		w:          w,
+	}
+	if len(at.headers) > 0 {
+		at.vars = &headerVars{
+			clientIP:    sc.ClientIP,
+			backend:     backend.URL,
+			backendHost: targetURL.Host,
+			host:        r.Host,
+			method:      r.Method,
+			path:        r.URL.EscapedPath(),
+		}
+		at.vars.requestID, _ = r.Context().Value(requestIDKey{}).(string)
+		if rt := routeFrom(r); rt != nil {
+			at.vars.route = rt.Name
 		}
+	}
+
+	// Медленный ответ может быть продублирован запросом на другой бэкенд
+	if h.Hedging != nil && h.Hedging.applies(r) {
+		at.hedging = &hedgingTransport{
+			base:    h.transport(),
+			hedger:  h.Hedging,
+			pool:    h.BackendPool,
+			sc:      sc,
+			primary: backend,
+		}
+	}
+
+	// Таймаут попытки действует до получения заголовков ответа, чтобы не обрывать длинные тела
+	ctx, cancel := context.WithCancel(context.WithValue(r.Context(), attemptKey{}, at))
+	defer cancel()
+	if h.Retry.PerTryTimeout > 0 {
+		at.timer = time.AfterFunc(h.Retry.PerTryTimeout, func() {
+			at.timedOut.Store(true)
+			cancel()
+		})
+		defer at.timer.Stop()
+	}
 
-		h.BackendPool.MarkBackendAlive(backend.URL, false)
+	if try > 1 {
+		h.Logger.Infof("proxy %s -> %s (retry %d)", at.clientIP, backend.URL, try-1)
+	} else {
+		h.Logger.Infof("proxy %s -> %s", at.clientIP, backend.URL)
 	}
 
-	h.Logger.Infof("proxy %s -> %s", clientIP, backend.URL)
+	// Поток gRPC живёт дольше таймаутов http.Server: снимаем их с потока
+	// HTTP/2 клиента (с туннеля — после ответа 101). Соединение учитывается в
+	// ActiveConnections, пока туннель или поток открыт.
+	if h.streamsGRPC(r) {
+		rc := http.NewResponseController(w)
+		rc.SetReadDeadline(time.Time{})
+		rc.SetWriteDeadline(time.Time{})
+	}
 
 	backend.IncConnections()
 	defer backend.DecConnections()
 
-	proxy.ServeHTTP(w, r)
+	// Результат запроса и время до получения заголовков ответа передаются стратегии
+	at.start = time.Now()
+	h.reverseProxy().ServeHTTP(w, r.WithContext(ctx))
+
+	if at.latency == 0 {
+		at.latency = time.Since(at.start)
+	}
+	// Хеджирующий бэкенд, на котором запрос тоже упал, исключается из повторов
+	if at.err != nil && at.hedging != nil && at.hedging.failedHedge != nil {
+		sc.Exclude(at.hedging.failedHedge)
+	}
+	// Итог основного запроса уже учтён транспортом хеджирования
+	if at.hedging != nil && at.hedging.primaryHandled {
+		return at.err
+	}
+	h.BackendPool.Done(backend, at.outcome, at.latency)
+	return at.err
+}
+
+// writeProxyError отвечает клиенту по последней ошибке проксирования.
+func writeProxyError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, errPerTryTimeout) || errors.Is(err, context.DeadlineExceeded):
+		grpcstatus.Error(w, r, "Backend timeout", http.StatusGatewayTimeout)
+	case isCriticalError(err):
+		// Обработка критичных ошибок
+		grpcstatus.Error(w, r, "Service unavailable due to backend error", http.StatusServiceUnavailable)
+	default:
+		grpcstatus.Error(w, r, "Backend error", http.StatusBadGateway)
+	}
+}
+
+// pickBackend выбирает бэкенд для запроса. При включённой привязке первая
+// попытка идёт на бэкенд из cookie, пока он доступен, и продлевает cookie;
+// иначе выбор делает стратегия, а cookie выдаётся заново на новый бэкенд.
+func (h *ProxyHandler) pickBackend(w http.ResponseWriter, r *http.Request, sc *balancer.SelectionContext, attempt int) *balancer.Backend {
+	if h.Sticky != nil && attempt == 1 {
+		if pinned := h.Sticky.Pinned(r, h.BackendPool); pinned != nil {
+			// Срок привязки отсчитывается от последнего запроса: активного
+			// клиента не перебрасывает на другой бэкенд через TTL после первого
+			if h.Sticky.TTL > 0 {
+				h.Sticky.Issue(w, r, pinned)
+			}
+			return pinned
+		}
+	}
+
+	// Стратегия получает запрос и IP клиента, чтобы выбирать бэкенд по ключу
+	backend := h.BackendPool.Select(sc)
+	if backend != nil && h.Sticky != nil {
+		h.Sticky.Issue(w, r, backend)
+	}
+	return backend
 }
 
 // Функция для проверки критичности ошибки
@@ -108,18 +541,10 @@ func isCriticalError(err error) bool {
     return strings.Contains(err.Error(), "database") || strings.Contains(err.Error(), "network")
 }
 
-// getClientIP извлекает IP-адрес клиента из заголовков X-Real-IP, X-Forwarded-For или из RemoteAddr
-func getClientIP(r *http.Request) string {
-    if ip := r.Header.Get("X-Real-IP"); ip != "" {
-        return strings.TrimSpace(ip)
-    }
-
-    if ips := r.Header.Get("X-Forwarded-For"); ips != "" {
-        // Берём первый IP из списка (оригинальный клиент)
-        return strings.TrimSpace(strings.Split(ips, ",")[0])
-    }
-
-    // Если ничего нет в заголовках, берем IP из соединения
-    host, _, _ := net.SplitHostPort(r.RemoteAddr)
-    return host
+// clientIPResolver возвращает Resolver IP клиента (по умолчанию без доверенных прокси).
+func (h *ProxyHandler) clientIPResolver() *clientip.Resolver {
+	if h.ClientIP != nil {
+		return h.ClientIP
+	}
+	return clientip.Default
 }
@@ -0,0 +1,76 @@
+package proxy
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"time"
+)
+
+// DefaultRetryBodyLimit — сколько байт тела запроса буферизуется для повторов по умолчанию.
+const DefaultRetryBodyLimit = 64 << 10
+
+// IdempotencyKeyHeader — заголовок, которым клиент помечает неидемпотентный запрос как безопасный для повтора.
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// RetryPolicy задаёт повторы запроса на другой бэкенд при транспортных ошибках.
+// Нулевое значение означает одну попытку без повторов.
+type RetryPolicy struct {
+	MaxAttempts   int           // всего попыток, включая первую
+	PerTryTimeout time.Duration // таймаут получения заголовков ответа одной попытки (0 — без таймаута)
+	TotalTimeout  time.Duration // общий дедлайн запроса со всеми попытками (0 — без дедлайна)
+	MaxBodyBytes  int64         // предел буферизации тела для повтора (0 — DefaultRetryBodyLimit)
+}
+
+// attempts возвращает число попыток для запроса: повторяются только
+// идемпотентные запросы (GET, HEAD, OPTIONS или с Idempotency-Key).
+func (p RetryPolicy) attempts(r *http.Request) int {
+	if p.MaxAttempts <= 1 || !isIdempotent(r) {
+		return 1
+	}
+	return p.MaxAttempts
+}
+
+func (p RetryPolicy) bodyLimit() int64 {
+	if p.MaxBodyBytes <= 0 {
+		return DefaultRetryBodyLimit
+	}
+	return p.MaxBodyBytes
+}
+
+// isIdempotent сообщает, безопасно ли повторить запрос на другом бэкенде.
+func isIdempotent(r *http.Request) bool {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+	}
+	return r.Header.Get(IdempotencyKeyHeader) != ""
+}
+
+// replayableBody буферизует тело запроса до limit байт, чтобы его можно было
+// отправить повторно. Если тело больше лимита, возвращает false и тело,
+// которое можно прочитать один раз (прочитанная часть плюс остаток).
+func replayableBody(r *http.Request, limit int64) ([]byte, io.ReadCloser, bool, error) {
+	if r.Body == nil || r.Body == http.NoBody {
+		return nil, r.Body, true, nil
+	}
+	if r.ContentLength > limit {
+		return nil, r.Body, false, nil
+	}
+
+	buf, err := io.ReadAll(io.LimitReader(r.Body, limit+1))
+	if err != nil {
+		return nil, nil, false, err
+	}
+	if int64(len(buf)) > limit {
+		return nil, readCloser{io.MultiReader(bytes.NewReader(buf), r.Body), r.Body}, false, nil
+	}
+	r.Body.Close()
+	return buf, nil, true, nil
+}
+
+// readCloser склеивает Reader и Closer исходного тела.
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
@@ -0,0 +1,189 @@
+package proxy
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/mk/loadBalancer/internal/grpcstatus"
+	"google.golang.org/grpc/codes"
+)
+
+// Route — правило маршрутизации запроса в пул бэкендов. Незаданные условия
+// не проверяются; запрос должен подходить под все заданные.
+type Route struct {
+	Name         string
+	Host         string            // Host запроса без порта, допускается *.example.com
+	PathPrefix   string            // префикс пути
+	PathRegex    *regexp.Regexp    // регулярное выражение для пути
+	Methods      []string          // HTTP-методы
+	Headers      map[string]string // заголовки и их значения ("" — заголовок задан)
+	GRPCService  string            // сервис gRPC (package.Service); правило подходит только вызовам gRPC
+	GRPCMethod   string            // метод сервиса gRPC
+	Priority     int               // правило с большим приоритетом проверяется раньше
+	Rewrite      *PathRewrite      // изменение пути перед отправкой на бэкенд (nil — без изменений)
+	HeaderPolicy *HeaderPolicy     // правила заголовков маршрута, применяются после правил пула
+	Handler      http.Handler      // обработчик пула
+}
+
+// PathRewrite описывает, как путь запроса меняется перед отправкой на бэкенд.
+// Сначала снимается StripPrefix (только по границе сегмента), затем применяется
+// Regex с подстановкой Replacement ($1, ${name} — группы выражения).
+type PathRewrite struct {
+	StripPrefix string
+	Regex       *regexp.Regexp
+	Replacement string
+}
+
+// Apply возвращает путь для бэкенда.
+func (pw *PathRewrite) Apply(path string) string {
+	// Префикс снимается только целыми сегментами: /api/users не снимается с /api/usersX
+	if pw.StripPrefix != "" {
+		if rest, ok := strings.CutPrefix(path, pw.StripPrefix); ok &&
+			(rest == "" || rest[0] == '/' || strings.HasSuffix(pw.StripPrefix, "/")) {
+			path = rest
+		}
+	}
+	if pw.Regex != nil {
+		path = pw.Regex.ReplaceAllString(path, pw.Replacement)
+	}
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+	return path
+}
+
+type routeKey struct{}
+
+// withRoute передаёт сработавшее правило обработчику прокси через контекст:
+// путь и заголовки меняются только в запросе к бэкенду, а остальная обработка
+// (хеджирование, ключ хеширования) видит исходный запрос.
+func withRoute(next http.Handler, rt *Route) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := context.WithValue(r.Context(), routeKey{}, rt)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// routeFrom возвращает правило, по которому запрос попал в пул (nil — без таблицы маршрутизации).
+func routeFrom(r *http.Request) *Route {
+	rt, _ := r.Context().Value(routeKey{}).(*Route)
+	return rt
+}
+
+// Match сообщает, подходит ли запрос под правило.
+func (rt *Route) Match(r *http.Request) bool {
+	if rt.Host != "" && !matchHost(rt.Host, r.Host) {
+		return false
+	}
+	if rt.PathPrefix != "" && !strings.HasPrefix(r.URL.Path, rt.PathPrefix) {
+		return false
+	}
+	if rt.PathRegex != nil && !rt.PathRegex.MatchString(r.URL.Path) {
+		return false
+	}
+	if len(rt.Methods) > 0 && !containsFold(rt.Methods, r.Method) {
+		return false
+	}
+	for name, value := range rt.Headers {
+		got, ok := r.Header[http.CanonicalHeaderKey(name)]
+		if !ok || (value != "" && !contains(got, value)) {
+			return false
+		}
+	}
+	if (rt.GRPCService != "" || rt.GRPCMethod != "") && !matchGRPC(r, rt.GRPCService, rt.GRPCMethod) {
+		return false
+	}
+	return true
+}
+
+// matchHost сравнивает Host запроса с шаблоном без учёта порта и регистра.
+func matchHost(pattern, host string) bool {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.ToLower(host)
+	pattern = strings.ToLower(pattern)
+	if suffix, ok := strings.CutPrefix(pattern, "*"); ok {
+		return strings.HasSuffix(host, suffix) && len(host) > len(suffix)
+	}
+	return host == pattern
+}
+
+func containsFold(values []string, s string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, s) {
+			return true
+		}
+	}
+	return false
+}
+
+func contains(values []string, s string) bool {
+	for _, v := range values {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// RoutingTable — таблица маршрутизации по пулам. Правила упорядочены по
+// убыванию приоритета, при равенстве сохраняется порядок объявления.
+type RoutingTable struct {
+	routes []*Route
+}
+
+// NewRoutingTable создаёт таблицу маршрутизации из правил.
+func NewRoutingTable(routes []*Route) *RoutingTable {
+	sorted := append([]*Route(nil), routes...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Priority > sorted[j].Priority
+	})
+	return &RoutingTable{routes: sorted}
+}
+
+// Routes возвращает правила в порядке проверки.
+func (t *RoutingTable) Routes() []*Route {
+	return t.routes
+}
+
+// Match возвращает первое подходящее правило или nil.
+func (t *RoutingTable) Match(r *http.Request) *Route {
+	for _, rt := range t.routes {
+		if rt.Match(r) {
+			return rt
+		}
+	}
+	return nil
+}
+
+// Register добавляет правила в mux.Router в порядке проверки. mux выбирает
+// первый подходящий маршрут, поэтому порядок совпадает с Match. Если у
+// router нет своего NotFoundHandler, вызовы gRPC без маршрута получают
+// UNIMPLEMENTED вместо HTTP 404.
+func (t *RoutingTable) Register(router *mux.Router) {
+	for _, rt := range t.routes {
+		rt := rt
+		route := router.MatcherFunc(func(r *http.Request, _ *mux.RouteMatch) bool {
+			return rt.Match(r)
+		}).Handler(withRoute(rt.Handler, rt))
+		if rt.Name != "" {
+			route.Name(rt.Name)
+		}
+	}
+	// Вызов gRPC, не подошедший ни под одно правило, получает UNIMPLEMENTED
+	if router.NotFoundHandler == nil {
+		router.NotFoundHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if grpcstatus.IsGRPC(r) {
+				grpcstatus.Write(w, codes.Unimplemented, "no route for "+r.URL.Path)
+				return
+			}
+			http.NotFound(w, r)
+		})
+	}
+}
@@ -0,0 +1,160 @@
+package proxy
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mk/loadBalancer/internal/balancer"
+)
+
+// DefaultStickyCookieName — имя cookie привязки по умолчанию.
+const DefaultStickyCookieName = "lb_backend"
+
+// StickySessions привязывает клиента к бэкенду с помощью подписанной cookie.
+// Cookie содержит идентификатор бэкенда (не его URL), время истечения и HMAC-SHA256 подпись.
+type StickySessions struct {
+	CookieName string
+	TTL        time.Duration
+	key        []byte
+}
+
+// NewStickySessions создаёт привязку с заданными параметрами. Если ключ
+// подписи пуст, генерируется случайный: cookie перестанут приниматься после перезапуска.
+func NewStickySessions(cookieName string, ttl time.Duration, signingKey string) (*StickySessions, error) {
+	if cookieName == "" {
+		cookieName = DefaultStickyCookieName
+	}
+	key := []byte(signingKey)
+	if len(key) == 0 {
+		key = make([]byte, 32)
+		if _, err := rand.Read(key); err != nil {
+			return nil, err
+		}
+	}
+	return &StickySessions{CookieName: cookieName, TTL: ttl, key: key}, nil
+}
+
+// ForPool возвращает привязку с тем же ключом подписи и TTL, но с cookie
+// CookieName_<pool>: у каждого пула своя cookie, и выдача её в одном пуле не
+// затирает привязку клиента в другом.
+func (s *StickySessions) ForPool(pool string) *StickySessions {
+	scoped := *s
+	scoped.CookieName = s.CookieName + "_" + cookieToken(pool)
+	return &scoped
+}
+
+// cookieToken заменяет символы, недопустимые в имени cookie, на '_'.
+func cookieToken(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r > ' ' && r < 0x7f && !strings.ContainsRune("()<>@,;:\\\"/[]?={}", r) {
+			return r
+		}
+		return '_'
+	}, s)
+}
+
+// Pinned возвращает доступный бэкенд, указанный в корректной cookie запроса, или nil.
+func (s *StickySessions) Pinned(r *http.Request, pool *balancer.ServerPool) *balancer.Backend {
+	c, err := r.Cookie(s.CookieName)
+	if err != nil {
+		return nil
+	}
+	id, ok := s.verify(c.Value)
+	if !ok {
+		return nil
+	}
+	for _, b := range pool.AllBackends() {
+		if backendID(b.URL) == id {
+			if b.IsAvailable() && b.AllowRequest() {
+				return b
+			}
+			return nil
+		}
+	}
+	return nil
+}
+
+// Issue выставляет в ответ cookie, привязывающую клиента к бэкенду. Cookie,
+// выданная раньше в этом же ответе (например, до повтора на другой бэкенд), заменяется.
+func (s *StickySessions) Issue(w http.ResponseWriter, r *http.Request, b *balancer.Backend) {
+	prefix := s.CookieName + "="
+	cookies := w.Header()["Set-Cookie"]
+	kept := cookies[:0]
+	for _, c := range cookies {
+		if !strings.HasPrefix(c, prefix) {
+			kept = append(kept, c)
+		}
+	}
+	if len(kept) == 0 {
+		w.Header().Del("Set-Cookie")
+	} else {
+		w.Header()["Set-Cookie"] = kept
+	}
+
+	cookie := &http.Cookie{
+		Name:     s.CookieName,
+		Value:    s.sign(backendID(b.URL), s.expiry()),
+		Path:     "/",
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteLaxMode,
+	}
+	if s.TTL > 0 {
+		cookie.MaxAge = int(s.TTL.Seconds())
+	}
+	http.SetCookie(w, cookie)
+}
+
+// expiry возвращает время истечения привязки в секундах Unix (0 — без срока).
+func (s *StickySessions) expiry() int64 {
+	if s.TTL <= 0 {
+		return 0
+	}
+	return time.Now().Add(s.TTL).Unix()
+}
+
+// sign формирует значение cookie вида "<id>.<expiry>.<подпись>".
+func (s *StickySessions) sign(id string, expiry int64) string {
+	payload := id + "." + strconv.FormatInt(expiry, 10)
+	return payload + "." + s.mac(payload)
+}
+
+// verify проверяет подпись и срок действия cookie и возвращает идентификатор бэкенда.
+func (s *StickySessions) verify(value string) (string, bool) {
+	i := strings.LastIndexByte(value, '.')
+	if i < 0 {
+		return "", false
+	}
+	payload, signature := value[:i], value[i+1:]
+	if !hmac.Equal([]byte(signature), []byte(s.mac(payload))) {
+		return "", false
+	}
+
+	id, exp, ok := strings.Cut(payload, ".")
+	if !ok {
+		return "", false
+	}
+	expiry, err := strconv.ParseInt(exp, 10, 64)
+	if err != nil || (expiry != 0 && time.Now().Unix() > expiry) {
+		return "", false
+	}
+	return id, true
+}
+
+func (s *StickySessions) mac(payload string) string {
+	m := hmac.New(sha256.New, s.key)
+	m.Write([]byte(payload))
+	return hex.EncodeToString(m.Sum(nil))
+}
+
+// backendID — короткий стабильный идентификатор бэкенда, не раскрывающий его URL.
+func backendID(url string) string {
+	sum := sha256.Sum256([]byte(url))
+	return hex.EncodeToString(sum[:8])
+}
@@ -0,0 +1,153 @@
+package proxy
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/mk/loadBalancer/internal/proxyproto"
+	"golang.org/x/net/http2"
+)
+
+// Протоколы соединений пула с бэкендами.
+const (
+	ProtocolAuto  = ""      // HTTP/1.1; с бэкендами https:// — HTTP/2, если бэкенд его предлагает
+	ProtocolHTTP1 = "http1" // только HTTP/1.1
+	ProtocolHTTP2 = "http2" // только HTTP/2 по TLS
+	ProtocolH2C   = "h2c"   // HTTP/2 без TLS (prior knowledge)
+)
+
+// TransportConfig — параметры соединений с бэкендами.
+type TransportConfig struct {
+	MaxIdleConns          int           // всего простаивающих соединений
+	MaxIdleConnsPerHost   int           // простаивающих соединений на один бэкенд
+	IdleConnTimeout       time.Duration // через сколько закрывается простаивающее соединение
+	DialTimeout           time.Duration // таймаут установки TCP-соединения
+	KeepAlive             time.Duration // период TCP keep-alive
+	TLSHandshakeTimeout   time.Duration // таймаут TLS-рукопожатия
+	ResponseHeaderTimeout time.Duration // ожидание заголовков ответа (0 — без ограничения)
+	DisableKeepAlives     bool          // новое соединение на каждый запрос
+	HTTP2                 bool          // пытаться договориться о HTTP/2 с бэкендом
+	ProxyProtocol         int           // версия PROXY protocol для бэкендов (0 — не отправлять)
+	TLS                   *tls.Config   // TLS к бэкендам https:// (nil — системные корневые сертификаты)
+}
+
+// DefaultTransportConfig возвращает параметры транспорта по умолчанию.
+func DefaultTransportConfig() TransportConfig {
+	return TransportConfig{
+		MaxIdleConns:        512,
+		MaxIdleConnsPerHost: 64,
+		IdleConnTimeout:     90 * time.Second,
+		DialTimeout:         5 * time.Second,
+		KeepAlive:           30 * time.Second,
+		TLSHandshakeTimeout: 5 * time.Second,
+		HTTP2:               true,
+	}
+}
+
+// NewTransport создаёт http.Transport для проксирования на бэкенды. В отличие от
+// http.DefaultTransport, держит достаточно простаивающих соединений на бэкенд,
+// чтобы под нагрузкой не открывать новое соединение на каждый запрос.
+func NewTransport(cfg TransportConfig) *http.Transport {
+	dialer := &net.Dialer{
+		Timeout:   cfg.DialTimeout,
+		KeepAlive: cfg.KeepAlive,
+	}
+	transport := &http.Transport{
+		Proxy:                 nil, // балансировщик ходит на бэкенды напрямую
+		DialContext:           dialer.DialContext,
+		MaxIdleConns:          cfg.MaxIdleConns,
+		MaxIdleConnsPerHost:   cfg.MaxIdleConnsPerHost,
+		IdleConnTimeout:       cfg.IdleConnTimeout,
+		TLSHandshakeTimeout:   cfg.TLSHandshakeTimeout,
+		ResponseHeaderTimeout: cfg.ResponseHeaderTimeout,
+		ExpectContinueTimeout: time.Second,
+		DisableKeepAlives:     cfg.DisableKeepAlives,
+		ForceAttemptHTTP2:     cfg.HTTP2,
+	}
+	if cfg.TLS != nil {
+		// Транспорт дописывает в конфигурацию ALPN, поэтому отдаём ему копию
+		transport.TLSClientConfig = cfg.TLS.Clone()
+	}
+	if cfg.ProxyProtocol != 0 {
+		// Заголовок описывает одного клиента, поэтому соединение не переиспользуется
+		// для запросов других клиентов
+		transport.DisableKeepAlives = true
+		transport.ForceAttemptHTTP2 = false
+		transport.DialContext = proxyProtocolDialer(dialer, cfg.ProxyProtocol)
+	}
+	return transport
+}
+
+// NewHTTP2Transport создаёт транспорт, который говорит с бэкендами только по
+// HTTP/2: по TLS или, если cleartext, без TLS (h2c). Запросы к одному бэкенду
+// мультиплексируются в общем соединении, поэтому PROXY protocol с ним не
+// используется. Соединение без входящих кадров дольше KeepAlive проверяется пингом.
+func NewHTTP2Transport(cfg TransportConfig, cleartext bool) *http2.Transport {
+	dialer := &net.Dialer{
+		Timeout:   cfg.DialTimeout,
+		KeepAlive: cfg.KeepAlive,
+	}
+	transport := &http2.Transport{
+		IdleConnTimeout: cfg.IdleConnTimeout,
+		ReadIdleTimeout: cfg.KeepAlive,
+	}
+	if cfg.TLS != nil {
+		transport.TLSClientConfig = cfg.TLS.Clone()
+	}
+	if cleartext {
+		transport.AllowHTTP = true
+		transport.DialTLSContext = func(ctx context.Context, network, addr string, _ *tls.Config) (net.Conn, error) {
+			return dialer.DialContext(ctx, network, addr)
+		}
+		return transport
+	}
+	transport.DialTLSContext = func(ctx context.Context, network, addr string, tlsConfig *tls.Config) (net.Conn, error) {
+		tlsDialer := &tls.Dialer{NetDialer: dialer, Config: tlsConfig}
+		return tlsDialer.DialContext(ctx, network, addr)
+	}
+	return transport
+}
+
+// proxyProtocolDialer устанавливает соединение с бэкендом и отправляет
+// заголовок PROXY protocol с адресом клиента проксируемого запроса.
+func proxyProtocolDialer(dialer *net.Dialer, version int) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		conn, err := dialer.DialContext(ctx, network, addr)
+		if err != nil {
+			return nil, err
+		}
+		var source net.Addr
+		if at, ok := ctx.Value(attemptKey{}).(*attempt); ok {
+			source = at.clientAddr()
+		}
+		if err := proxyproto.WriteHeader(conn, version, source); err != nil {
+			conn.Close()
+			return nil, err
+		}
+		return conn, nil
+	}
+}
+
+// bufferPool переиспользует буферы копирования тел ответов между запросами.
+type bufferPool struct {
+	pool sync.Pool
+}
+
+func newBufferPool(size int) *bufferPool {
+	return &bufferPool{pool: sync.Pool{New: func() any {
+		buf := make([]byte, size)
+		return &buf
+	}}}
+}
+
+func (p *bufferPool) Get() []byte {
+	return *p.pool.Get().(*[]byte)
+}
+
+func (p *bufferPool) Put(buf []byte) {
+	p.pool.Put(&buf)
+}
@@ -0,0 +1,196 @@
+package proxy
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/mk/loadBalancer/internal/balancer"
+	"go.uber.org/zap"
+)
+
+// isUpgrade сообщает, просит ли клиент сменить протокол (WebSocket и т.п.).
+func isUpgrade(r *http.Request) bool {
+	if r.Header.Get("Upgrade") == "" {
+		return false
+	}
+	for _, value := range r.Header.Values("Connection") {
+		for _, token := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(token), "upgrade") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Tunnels учитывает соединения после смены протокола (101 Switching Protocols)
+// и ограничивает их время простоя и жизни. http.Server после Hijack такие
+// соединения не отслеживает, поэтому при остановке их закрывает Shutdown.
+type Tunnels struct {
+	IdleTimeout time.Duration // закрыть туннель без данных в обе стороны (0 — без ограничения)
+	MaxLifetime time.Duration // закрыть туннель после этого времени (0 — без ограничения)
+	Logger      *zap.SugaredLogger
+
+	mu     sync.Mutex
+	active map[*tunnel]struct{}
+	wg     sync.WaitGroup
+}
+
+// NewTunnels создаёт учёт туннелей.
+func NewTunnels(idleTimeout, maxLifetime time.Duration, logger *zap.SugaredLogger) *Tunnels {
+	return &Tunnels{
+		IdleTimeout: idleTimeout,
+		MaxLifetime: maxLifetime,
+		Logger:      logger,
+		active:      make(map[*tunnel]struct{}),
+	}
+}
+
+// Active возвращает число открытых туннелей.
+func (t *Tunnels) Active() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return len(t.active)
+}
+
+// Shutdown закрывает все туннели и ждёт, пока прокси освободит их ресурсы.
+func (t *Tunnels) Shutdown(ctx context.Context) error {
+	t.mu.Lock()
+	tunnels := make([]*tunnel, 0, len(t.active))
+	for tn := range t.active {
+		tunnels = append(tunnels, tn)
+	}
+	t.mu.Unlock()
+	for _, tn := range tunnels {
+		tn.closeWith("shutdown")
+	}
+
+	done := make(chan struct{})
+	go func() {
+		t.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// track оборачивает соединение с бэкендом после смены протокола. Через него
+// идут данные в обе стороны, поэтому по нему видно и простой туннеля.
+func (t *Tunnels) track(conn io.ReadWriteCloser, backend *balancer.Backend) io.ReadWriteCloser {
+	tn := &tunnel{ReadWriteCloser: conn, owner: t, backend: backend, opened: time.Now()}
+	tn.touch()
+
+	t.mu.Lock()
+	t.active[tn] = struct{}{}
+	t.wg.Add(1)
+	t.mu.Unlock()
+
+	tn.mu.Lock()
+	if t.IdleTimeout > 0 {
+		tn.idleTimer = time.AfterFunc(t.IdleTimeout, tn.checkIdle)
+	}
+	if t.MaxLifetime > 0 {
+		tn.lifeTimer = time.AfterFunc(t.MaxLifetime, func() { tn.closeWith("max lifetime reached") })
+	}
+	tn.mu.Unlock()
+	t.logger().Infow("upgraded connection opened", "backend", backend.URL)
+	return tn
+}
+
+func (t *Tunnels) logger() *zap.SugaredLogger {
+	if t.Logger != nil {
+		return t.Logger
+	}
+	return zap.NewNop().Sugar()
+}
+
+// tunnel — соединение с бэкендом после смены протокола.
+type tunnel struct {
+	io.ReadWriteCloser
+	owner   *Tunnels
+	backend *balancer.Backend
+	opened  time.Time
+
+	lastActivity atomic.Int64 // unix-наносекунды последнего чтения или записи
+	mu           sync.Mutex   // защищает таймеры
+	idleTimer    *time.Timer
+	lifeTimer    *time.Timer
+	reason       atomic.Value // причина закрытия балансировщиком
+	closeOnce    sync.Once
+}
+
+func (tn *tunnel) touch() {
+	tn.lastActivity.Store(time.Now().UnixNano())
+}
+
+func (tn *tunnel) Read(p []byte) (int, error) {
+	n, err := tn.ReadWriteCloser.Read(p)
+	if n > 0 {
+		tn.touch()
+	}
+	return n, err
+}
+
+func (tn *tunnel) Write(p []byte) (int, error) {
+	n, err := tn.ReadWriteCloser.Write(p)
+	if n > 0 {
+		tn.touch()
+	}
+	return n, err
+}
+
+// checkIdle закрывает туннель, если данных не было дольше IdleTimeout, иначе
+// переносит проверку на момент, когда этот срок истечёт.
+func (tn *tunnel) checkIdle() {
+	idle := time.Since(time.Unix(0, tn.lastActivity.Load()))
+	if idle >= tn.owner.IdleTimeout {
+		tn.closeWith("idle timeout")
+		return
+	}
+	tn.mu.Lock()
+	tn.idleTimer.Reset(tn.owner.IdleTimeout - idle)
+	tn.mu.Unlock()
+}
+
+// closeWith закрывает туннель по решению балансировщика. Прокси, заметив
+// закрытие соединения с бэкендом, закрывает и соединение клиента.
+func (tn *tunnel) closeWith(reason string) {
+	tn.reason.CompareAndSwap(nil, reason)
+	tn.Close()
+}
+
+// Close вызывает прокси по завершении туннеля (и closeWith).
+func (tn *tunnel) Close() error {
+	err := tn.ReadWriteCloser.Close()
+	tn.closeOnce.Do(func() {
+		tn.mu.Lock()
+		if tn.idleTimer != nil {
+			tn.idleTimer.Stop()
+		}
+		if tn.lifeTimer != nil {
+			tn.lifeTimer.Stop()
+		}
+		tn.mu.Unlock()
+		tn.owner.mu.Lock()
+		delete(tn.owner.active, tn)
+		tn.owner.mu.Unlock()
+		tn.owner.wg.Done()
+
+		reason, _ := tn.reason.Load().(string)
+		if reason == "" {
+			reason = "closed by peer"
+		}
+		tn.owner.logger().Infow("upgraded connection closed",
+			"backend", tn.backend.URL, "duration", time.Since(tn.opened), "reason", reason)
+	})
+	return err
+}
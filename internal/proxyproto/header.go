@@ -0,0 +1,233 @@
+// Пакет proxyproto реализует PROXY protocol HAProxy версий 1 (текстовая) и 2
+// (бинарная): разбор заголовка на входящих соединениях и его отправку бэкендам.
+package proxyproto
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// Версии протокола.
+const (
+	V1 = 1
+	V2 = 2
+)
+
+// v2Signature — первые 12 байт заголовка версии 2.
+var v2Signature = []byte("\r\n\r\n\x00\r\nQUIT\n")
+
+// v1Prefix — начало заголовка версии 1.
+var v1Prefix = []byte("PROXY ")
+
+const (
+	v1MaxLength = 107 // максимальная длина строки версии 1 вместе с CRLF
+	v2HeaderLen = 16  // сигнатура, версия/команда, семейство, длина
+
+	v2CmdLocal = 0x0
+	v2CmdProxy = 0x1
+
+	v2FamUnspec = 0x00
+	v2FamTCP4   = 0x11
+	v2FamTCP6   = 0x21
+)
+
+// ErrNoHeader — соединение не начинается с заголовка PROXY protocol.
+var ErrNoHeader = errors.New("proxyproto: no PROXY protocol header")
+
+// Header — заголовок PROXY protocol. Source и Destination равны nil, если
+// отправитель не передал адреса (LOCAL в версии 2, UNKNOWN в версии 1).
+type Header struct {
+	Version     int
+	Source      *net.TCPAddr
+	Destination *net.TCPAddr
+}
+
+// Read читает заголовок из начала потока. Если поток не начинается с
+// заголовка, возвращается ErrNoHeader и ни один байт не потребляется.
+func Read(r *bufio.Reader) (*Header, error) {
+	sig, err := r.Peek(len(v1Prefix))
+	if err != nil {
+		if len(sig) > 0 && !bytes.HasPrefix(v1Prefix, sig) && !bytes.HasPrefix(v2Signature, sig) {
+			return nil, ErrNoHeader
+		}
+		return nil, err
+	}
+	if bytes.Equal(sig, v1Prefix) {
+		return readV1(r)
+	}
+	if !bytes.Equal(sig, v2Signature[:len(sig)]) {
+		return nil, ErrNoHeader
+	}
+	sig, err = r.Peek(len(v2Signature))
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(sig, v2Signature) {
+		return nil, ErrNoHeader
+	}
+	return readV2(r)
+}
+
+func readV1(r *bufio.Reader) (*Header, error) {
+	var line []byte
+	for len(line) < v1MaxLength {
+		b, err := r.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		line = append(line, b)
+		if b == '\n' {
+			break
+		}
+	}
+	if !bytes.HasSuffix(line, []byte("\r\n")) {
+		return nil, errors.New("proxyproto: v1 header is too long or not terminated by CRLF")
+	}
+
+	fields := strings.Fields(string(line[:len(line)-2]))
+	if len(fields) >= 2 && fields[1] == "UNKNOWN" {
+		return &Header{Version: V1}, nil
+	}
+	if len(fields) != 6 || (fields[1] != "TCP4" && fields[1] != "TCP6") {
+		return nil, fmt.Errorf("proxyproto: malformed v1 header %q", line)
+	}
+	src, err := parseV1Addr(fields[2], fields[4])
+	if err != nil {
+		return nil, err
+	}
+	dst, err := parseV1Addr(fields[3], fields[5])
+	if err != nil {
+		return nil, err
+	}
+	return &Header{Version: V1, Source: src, Destination: dst}, nil
+}
+
+func parseV1Addr(ip, port string) (*net.TCPAddr, error) {
+	addr := net.ParseIP(ip)
+	if addr == nil {
+		return nil, fmt.Errorf("proxyproto: invalid address %q", ip)
+	}
+	p, err := strconv.ParseUint(port, 10, 16)
+	if err != nil {
+		return nil, fmt.Errorf("proxyproto: invalid port %q", port)
+	}
+	return &net.TCPAddr{IP: addr, Port: int(p)}, nil
+}
+
+func readV2(r *bufio.Reader) (*Header, error) {
+	var head [v2HeaderLen]byte
+	if _, err := io.ReadFull(r, head[:]); err != nil {
+		return nil, err
+	}
+	if head[12]>>4 != 2 {
+		return nil, fmt.Errorf("proxyproto: unsupported v2 version %d", head[12]>>4)
+	}
+	command := head[12] & 0x0f
+	family := head[13]
+	length := int(binary.BigEndian.Uint16(head[14:16]))
+
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return nil, err
+	}
+
+	h := &Header{Version: V2}
+	if command == v2CmdLocal {
+		return h, nil
+	}
+	if command != v2CmdProxy {
+		return nil, fmt.Errorf("proxyproto: unsupported v2 command %d", command)
+	}
+
+	// TLV после адресов не используются и пропускаются
+	switch family {
+	case v2FamTCP4:
+		if length < 12 {
+			return nil, errors.New("proxyproto: short v2 IPv4 address block")
+		}
+		h.Source = &net.TCPAddr{IP: net.IP(payload[0:4]), Port: int(binary.BigEndian.Uint16(payload[8:10]))}
+		h.Destination = &net.TCPAddr{IP: net.IP(payload[4:8]), Port: int(binary.BigEndian.Uint16(payload[10:12]))}
+	case v2FamTCP6:
+		if length < 36 {
+			return nil, errors.New("proxyproto: short v2 IPv6 address block")
+		}
+		h.Source = &net.TCPAddr{IP: net.IP(payload[0:16]), Port: int(binary.BigEndian.Uint16(payload[32:34]))}
+		h.Destination = &net.TCPAddr{IP: net.IP(payload[16:32]), Port: int(binary.BigEndian.Uint16(payload[34:36]))}
+	default:
+		// UDP, UNIX-сокеты и UNSPEC: адреса не передаются
+	}
+	return h, nil
+}
+
+// Format кодирует заголовок в указанной версии. Без адресов или при разных
+// семействах адресов отправляется UNKNOWN (v1) или LOCAL (v2).
+func (h *Header) Format(version int) ([]byte, error) {
+	switch version {
+	case V1:
+		return h.formatV1(), nil
+	case V2:
+		return h.formatV2(), nil
+	}
+	return nil, fmt.Errorf("proxyproto: unsupported version %d", version)
+}
+
+// family возвращает 4 или 6 для пары адресов одного семейства, иначе 0.
+func (h *Header) family() int {
+	if h.Source == nil || h.Destination == nil {
+		return 0
+	}
+	src4, dst4 := h.Source.IP.To4() != nil, h.Destination.IP.To4() != nil
+	switch {
+	case src4 && dst4:
+		return 4
+	case !src4 && !dst4:
+		return 6
+	}
+	return 0
+}
+
+func (h *Header) formatV1() []byte {
+	var proto string
+	switch h.family() {
+	case 4:
+		proto = "TCP4"
+	case 6:
+		proto = "TCP6"
+	default:
+		return []byte("PROXY UNKNOWN\r\n")
+	}
+	return []byte(fmt.Sprintf("PROXY %s %s %s %d %d\r\n", proto,
+		h.Source.IP.String(), h.Destination.IP.String(), h.Source.Port, h.Destination.Port))
+}
+
+func (h *Header) formatV2() []byte {
+	buf := make([]byte, v2HeaderLen, v2HeaderLen+36)
+	copy(buf, v2Signature)
+	switch h.family() {
+	case 4:
+		buf[12] = 0x20 | v2CmdProxy
+		buf[13] = v2FamTCP4
+		buf = append(buf, h.Source.IP.To4()...)
+		buf = append(buf, h.Destination.IP.To4()...)
+	case 6:
+		buf[12] = 0x20 | v2CmdProxy
+		buf[13] = v2FamTCP6
+		buf = append(buf, h.Source.IP.To16()...)
+		buf = append(buf, h.Destination.IP.To16()...)
+	default:
+		buf[12] = 0x20 | v2CmdLocal
+		buf[13] = v2FamUnspec
+		return buf
+	}
+	buf = binary.BigEndian.AppendUint16(buf, uint16(h.Source.Port))
+	buf = binary.BigEndian.AppendUint16(buf, uint16(h.Destination.Port))
+	binary.BigEndian.PutUint16(buf[14:16], uint16(len(buf)-v2HeaderLen))
+	return buf
+}
@@ -0,0 +1,121 @@
+package proxyproto
+
+import (
+	"bufio"
+	"errors"
+	"net"
+	"sync"
+	"time"
+)
+
+// DefaultHeaderTimeout — сколько ждать заголовок от доверенного источника.
+const DefaultHeaderTimeout = 5 * time.Second
+
+// Listener принимает соединения и разбирает заголовок PROXY protocol у
+// соединений от доверенных источников. RemoteAddr и LocalAddr таких
+// соединений возвращают адреса из заголовка. Соединения от остальных
+// источников не разбираются: присланный ими заголовок останется в потоке и
+// HTTP-сервер отклонит запрос.
+type Listener struct {
+	net.Listener
+	Trusted       func(ip string) bool // доверенный ли источник (nil — доверять всем)
+	HeaderTimeout time.Duration        // таймаут чтения заголовка (0 — DefaultHeaderTimeout)
+}
+
+// NewListener оборачивает net.Listener.
+func NewListener(inner net.Listener, trusted func(ip string) bool, headerTimeout time.Duration) *Listener {
+	return &Listener{Listener: inner, Trusted: trusted, HeaderTimeout: headerTimeout}
+}
+
+// Accept возвращает соединение. Заголовок читается не здесь, а при первом
+// обращении к соединению, чтобы медленный источник не задерживал Accept.
+func (l *Listener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	host, _, _ := net.SplitHostPort(conn.RemoteAddr().String())
+	if l.Trusted != nil && !l.Trusted(host) {
+		return conn, nil
+	}
+	timeout := l.HeaderTimeout
+	if timeout <= 0 {
+		timeout = DefaultHeaderTimeout
+	}
+	return &Conn{Conn: conn, reader: bufio.NewReader(conn), timeout: timeout}, nil
+}
+
+// Conn — соединение от доверенного источника с (возможным) заголовком PROXY protocol.
+type Conn struct {
+	net.Conn
+	reader  *bufio.Reader
+	timeout time.Duration
+
+	once   sync.Once
+	header *Header
+	err    error
+}
+
+// readHeader читает заголовок один раз. Соединение без заголовка допускается:
+// источник может обращаться к балансировщику и напрямую.
+func (c *Conn) readHeader() {
+	c.once.Do(func() {
+		c.Conn.SetReadDeadline(time.Now().Add(c.timeout))
+		header, err := Read(c.reader)
+		c.Conn.SetReadDeadline(time.Time{})
+		if errors.Is(err, ErrNoHeader) {
+			return
+		}
+		c.header, c.err = header, err
+	})
+}
+
+// Header возвращает разобранный заголовок (nil — заголовка не было).
+func (c *Conn) Header() (*Header, error) {
+	c.readHeader()
+	return c.header, c.err
+}
+
+func (c *Conn) Read(p []byte) (int, error) {
+	c.readHeader()
+	if c.err != nil {
+		return 0, c.err
+	}
+	return c.reader.Read(p)
+}
+
+// RemoteAddr возвращает адрес клиента из заголовка или адрес соединения.
+func (c *Conn) RemoteAddr() net.Addr {
+	c.readHeader()
+	if c.header != nil && c.header.Source != nil {
+		return c.header.Source
+	}
+	return c.Conn.RemoteAddr()
+}
+
+// LocalAddr возвращает адрес назначения из заголовка или локальный адрес соединения.
+func (c *Conn) LocalAddr() net.Addr {
+	c.readHeader()
+	if c.header != nil && c.header.Destination != nil {
+		return c.header.Destination
+	}
+	return c.Conn.LocalAddr()
+}
+
+// WriteHeader отправляет в только что установленное соединение заголовок
+// PROXY protocol с адресом клиента source. Адрес назначения — адрес бэкенда.
+func WriteHeader(conn net.Conn, version int, source net.Addr) error {
+	h := &Header{Version: version}
+	if src, ok := source.(*net.TCPAddr); ok {
+		h.Source = src
+	}
+	if dst, ok := conn.RemoteAddr().(*net.TCPAddr); ok {
+		h.Destination = dst
+	}
+	buf, err := h.Format(version)
+	if err != nil {
+		return err
+	}
+	_, err = conn.Write(buf)
+	return err
+}
@@ -0,0 +1,119 @@
+package proxyproto
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"testing"
+)
+
+func TestHeaderRoundTrip(t *testing.T) {
+	cases := []struct {
+		name     string
+		src, dst *net.TCPAddr
+	}{
+		{"ipv4", &net.TCPAddr{IP: net.ParseIP("203.0.113.9"), Port: 4444}, &net.TCPAddr{IP: net.ParseIP("10.0.0.1"), Port: 80}},
+		{"ipv6", &net.TCPAddr{IP: net.ParseIP("2001:db8::9"), Port: 4444}, &net.TCPAddr{IP: net.ParseIP("2001:db8::1"), Port: 443}},
+	}
+	for _, tc := range cases {
+		for _, version := range []int{V1, V2} {
+			h := &Header{Version: version, Source: tc.src, Destination: tc.dst}
+			buf, err := h.Format(version)
+			if err != nil {
+				t.Fatalf("%s v%d: Format: %v", tc.name, version, err)
+			}
+			r := bufio.NewReader(io.MultiReader(bytes.NewReader(buf), strings.NewReader("GET / HTTP/1.1\r\n")))
+			got, err := Read(r)
+			if err != nil {
+				t.Fatalf("%s v%d: Read: %v", tc.name, version, err)
+			}
+			if got.Version != version || got.Source.String() != tc.src.String() || got.Destination.String() != tc.dst.String() {
+				t.Fatalf("%s v%d: expected %v -> %v, got %+v", tc.name, version, tc.src, tc.dst, got)
+			}
+			// После заголовка поток продолжается с данных клиента
+			if rest, _ := r.ReadString('\n'); rest != "GET / HTTP/1.1\r\n" {
+				t.Fatalf("%s v%d: unexpected payload %q", tc.name, version, rest)
+			}
+		}
+	}
+}
+
+func TestHeaderWithoutAddresses(t *testing.T) {
+	for _, version := range []int{V1, V2} {
+		buf, err := (&Header{}).Format(version)
+		if err != nil {
+			t.Fatalf("v%d: Format: %v", version, err)
+		}
+		got, err := Read(bufio.NewReader(bytes.NewReader(buf)))
+		if err != nil {
+			t.Fatalf("v%d: Read: %v", version, err)
+		}
+		if got.Source != nil || got.Destination != nil {
+			t.Fatalf("v%d: expected no addresses, got %+v", version, got)
+		}
+	}
+}
+
+func TestReadWithoutHeader(t *testing.T) {
+	r := bufio.NewReader(strings.NewReader("GET / HTTP/1.1\r\n"))
+	if _, err := Read(r); !errors.Is(err, ErrNoHeader) {
+		t.Fatalf("expected ErrNoHeader, got %v", err)
+	}
+	if line, _ := r.ReadString('\n'); line != "GET / HTTP/1.1\r\n" {
+		t.Fatalf("expected stream to be left intact, got %q", line)
+	}
+}
+
+func TestReadMalformedV1(t *testing.T) {
+	for _, header := range []string{
+		"PROXY TCP4 1.2.3.4\r\n",
+		"PROXY TCP4 1.2.3.4 5.6.7.8 1 99999\r\n",
+		"PROXY TCP4 " + strings.Repeat("1", 200) + "\r\n",
+	} {
+		if _, err := Read(bufio.NewReader(strings.NewReader(header))); err == nil {
+			t.Fatalf("expected error for %q", header)
+		}
+	}
+}
+
+func TestListenerTrustedSource(t *testing.T) {
+	inner, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer inner.Close()
+
+	for _, trusted := range []bool{true, false} {
+		ln := NewListener(inner, func(string) bool { return trusted }, 0)
+		src := &net.TCPAddr{IP: net.ParseIP("203.0.113.9"), Port: 4444}
+
+		client, err := net.Dial("tcp", inner.Addr().String())
+		if err != nil {
+			t.Fatalf("dial: %v", err)
+		}
+		if err := WriteHeader(client, V1, src); err != nil {
+			t.Fatalf("WriteHeader: %v", err)
+		}
+		client.Write([]byte("hello"))
+		client.Close()
+
+		conn, err := ln.Accept()
+		if err != nil {
+			t.Fatalf("accept: %v", err)
+		}
+		data, _ := io.ReadAll(conn)
+		conn.Close()
+
+		if trusted {
+			if conn.RemoteAddr().String() != src.String() || string(data) != "hello" {
+				t.Fatalf("trusted: expected %v and payload, got %v %q", src, conn.RemoteAddr(), data)
+			}
+		} else if !strings.HasPrefix(string(data), "PROXY TCP4") {
+			// Заголовок от недоверенного источника не разбирается
+			t.Fatalf("untrusted: expected raw header in stream, got %q", data)
+		}
+	}
+}
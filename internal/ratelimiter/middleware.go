@@ -2,11 +2,11 @@ package ratelimiter
 
 import (
 	"encoding/json"
-	"net"
 	"net/http"
-	"strings"
 
+	"github.com/mk/loadBalancer/internal/grpcstatus"
 	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
 )
 
 type ErrorResponse struct {
@@ -17,14 +17,17 @@ type ErrorResponse struct {
 func RateLimitMiddleware(rl *RateLimiter, logger *zap.SugaredLogger) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			clientID := r.Header.Get("X-Client-ID")
-			if clientID == "" {
-				clientID = extractClientIP(r) // fallback
-			}
+			clientID := rl.clientID(r)
 
 			if !rl.AllowRequest(clientID) {
 				logger.Warnw("Rate limit exceeded", "client_id", clientID)
 
+				// Клиент gRPC ждёт статус в трейлерах, а не JSON
+				if grpcstatus.IsGRPC(r) {
+					grpcstatus.Write(w, codes.ResourceExhausted, "rate limit exceeded")
+					return
+				}
+
 				w.Header().Set("Content-Type", "application/json")
 				w.WriteHeader(http.StatusTooManyRequests)
 
@@ -41,19 +44,3 @@ func RateLimitMiddleware(rl *RateLimiter, logger *zap.SugaredLogger) func(http.H
 	}
 }
 
-
-func extractClientIP(r *http.Request) string {
-	if ip, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
-		return ip
-	}
-
-	if ip := r.Header.Get("X-Real-IP"); ip != "" {
-		return ip
-	}
-
-	if ips := r.Header.Get("X-Forwarded-For"); ips != "" {
-		return strings.TrimSpace(strings.Split(ips, ",")[0])
-	}
-
-	return r.RemoteAddr
-}
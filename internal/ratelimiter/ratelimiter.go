@@ -1,9 +1,11 @@
 package ratelimiter
 
 import (
+	"net/http"
 	"sync"
 	"time"
 
+	"github.com/mk/loadBalancer/internal/clientip"
 	"github.com/mk/loadBalancer/internal/storage"
 	"go.uber.org/zap"
 )
@@ -15,6 +17,8 @@ type RateLimiter struct {
 	defaultCap    int
 	defaultRefill int
 	logger        *zap.SugaredLogger
+	resolver      *clientip.Resolver         // IP клиента для запросов без X-Client-ID
+	identity      func(*http.Request) string // идентификатор из клиентского сертификата (nil — X-Client-ID)
 }
 
 type ClientLimit struct {
@@ -34,6 +38,41 @@ func NewRateLimiter(capacity, refillRate int, repo storage.ClientRepository, log
 	return rl
 }
 
+// SetClientIPResolver задаёт определение IP клиента; тот же Resolver
+// использует прокси, чтобы лимит и логи видели одного и того же клиента.
+func (rl *RateLimiter) SetClientIPResolver(resolver *clientip.Resolver) {
+	rl.resolver = resolver
+}
+
+// SetClientIdentity задаёт проверенный идентификатор клиента, например поле
+// клиентского сертификата. Заголовок X-Client-ID после этого не используется:
+// запрос без идентификатора ограничивается по IP клиента.
+func (rl *RateLimiter) SetClientIdentity(identity func(*http.Request) string) {
+	rl.identity = identity
+}
+
+// clientID возвращает ключ, по которому ограничивается запрос.
+func (rl *RateLimiter) clientID(r *http.Request) string {
+	var id string
+	if rl.identity != nil {
+		id = rl.identity(r)
+	} else {
+		id = r.Header.Get("X-Client-ID")
+	}
+	if id == "" {
+		id = rl.clientIP(r) // fallback
+	}
+	return id
+}
+
+// clientIP возвращает IP клиента запроса.
+func (rl *RateLimiter) clientIP(r *http.Request) string {
+	if rl.resolver != nil {
+		return rl.resolver.ClientIP(r)
+	}
+	return clientip.Default.ClientIP(r)
+}
+
 func (rl *RateLimiter) AllowRequest(clientID string) bool {
 	rl.mu.Lock()
 	defer rl.mu.Unlock()
@@ -0,0 +1,185 @@
+package server
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"regexp"
+
+	"github.com/mk/loadBalancer/internal/balancer"
+	"github.com/mk/loadBalancer/internal/certs"
+	"github.com/mk/loadBalancer/internal/config"
+	"github.com/mk/loadBalancer/internal/proxy"
+	"github.com/mk/loadBalancer/internal/proxyproto"
+	"go.uber.org/zap"
+)
+
+// newPool создаёт пул бэкендов по его конфигурации. Health checks, outlier
+// detection, circuit breaker и бюджет повторов настраиваются одинаково для всех пулов.
+func newPool(appConfig *config.Config, poolConfig config.PoolConfig, logger *zap.SugaredLogger) (*balancer.ServerPool, error) {
+	backends := make([]*balancer.Backend, 0, len(poolConfig.Backends))
+	for _, b := range poolConfig.Backends {
+		backends = append(backends, balancer.NewWeightedBackend(b.URL, b.Weight))
+	}
+	backendPool := balancer.NewServerPoolFromBackends(backends)
+
+	// Стратегия балансировки
+	strategy, err := balancer.NewStrategy(poolConfig.Strategy, balancer.StrategyOptions{
+		HashKey:      appConfig.ConsistentHash.Key,
+		VirtualNodes: appConfig.ConsistentHash.VirtualNodes,
+	})
+	if err != nil {
+		return nil, err
+	}
+	backendPool.SetStrategy(strategy)
+	backendPool.SetLatencyDecay(appConfig.PeakEWMA.Decay)
+	backendPool.SetLogger(logger)
+	if od := appConfig.OutlierDetection; od.Enabled {
+		backendPool.SetOutlierDetector(balancer.NewOutlierDetector(balancer.OutlierConfig{
+			Consecutive5xx:           od.Consecutive5xx,
+			ConsecutiveGatewayErrors: od.ConsecutiveGatewayErrors,
+			BaseEjectionTime:         od.BaseEjectionTime,
+			MaxEjectionTime:          od.MaxEjectionTime,
+			MaxEjectionPercent:       od.MaxEjectionPercent,
+		}))
+	}
+	backendPool.SetHealthPolicy(balancer.HealthPolicy{
+		FallThreshold: appConfig.HealthCheck.FallThreshold,
+		RiseThreshold: appConfig.HealthCheck.RiseThreshold,
+		HoldDown:      appConfig.HealthCheck.HoldDown,
+		HistorySize:   appConfig.HealthCheck.HistorySize,
+	})
+
+	if cb := appConfig.CircuitBreaker; cb.Enabled {
+		backendPool.SetCircuitBreakers(balancer.BreakerConfig{
+			Window:           cb.Window,
+			MinRequests:      cb.MinRequests,
+			ErrorThreshold:   cb.ErrorThreshold,
+			OpenTimeout:      cb.OpenTimeout,
+			HalfOpenRequests: cb.HalfOpenRequests,
+		})
+	}
+
+	if budget := appConfig.Retries.Budget; budget.Percent > 0 || budget.MinPerSecond > 0 {
+		backendPool.SetRetryBudget(balancer.NewRetryBudget(budget.Percent, budget.MinPerSecond, budget.Window))
+	}
+	return backendPool, nil
+}
+
+// newChecker создаёт активный health checker пула: он возвращает восстановившиеся бэкенды в пул.
+func newChecker(appConfig *config.Config, poolConfig config.PoolConfig, backendPool *balancer.ServerPool) *balancer.Checker {
+	checker := balancer.NewPoolChecker(backendPool, appConfig.HealthCheck.Interval)
+	checker.Client.Timeout = appConfig.HealthCheck.Timeout
+	checker.Path = appConfig.HealthCheck.Path
+	checker.ExpectedStatus = appConfig.HealthCheck.ExpectedStatus
+	// Пул gRPC может проверяться по протоколу grpc.health.v1 через свой транспорт HTTP/2
+	checker.GRPC = poolConfig.HealthCheck.Type == "grpc"
+	checker.GRPCService = poolConfig.HealthCheck.Service
+	return checker
+}
+
+// newRoutingTable строит таблицу маршрутизации из правил конфигурации.
+func newRoutingTable(routeConfigs []config.RouteConfig, handlers map[string]http.Handler) (*proxy.RoutingTable, error) {
+	routes := make([]*proxy.Route, 0, len(routeConfigs))
+	for _, rc := range routeConfigs {
+		handler, ok := handlers[rc.Pool]
+		if !ok {
+			return nil, fmt.Errorf("route %s refers to unknown pool %q", rc.Name, rc.Pool)
+		}
+		route := &proxy.Route{
+			Name:        rc.Name,
+			Host:        rc.Host,
+			PathPrefix:  rc.PathPrefix,
+			Methods:     rc.Methods,
+			Headers:     rc.Headers,
+			GRPCService: rc.GRPC.Service,
+			GRPCMethod:  rc.GRPC.Method,
+			Priority:    rc.Priority,
+			Handler:     handler,
+		}
+		if rc.PathRegex != "" {
+			re, err := regexp.Compile(rc.PathRegex)
+			if err != nil {
+				return nil, fmt.Errorf("route %s: invalid path_regex: %w", rc.Name, err)
+			}
+			route.PathRegex = re
+		}
+		rewrite, err := newPathRewrite(rc)
+		if err != nil {
+			return nil, fmt.Errorf("route %s: %w", rc.Name, err)
+		}
+		route.Rewrite = rewrite
+		if route.HeaderPolicy, err = newHeaderPolicy(rc.RequestHeaders, rc.ResponseHeaders); err != nil {
+			return nil, fmt.Errorf("route %s: %w", rc.Name, err)
+		}
+		routes = append(routes, route)
+	}
+	return proxy.NewRoutingTable(routes), nil
+}
+
+// newPathRewrite строит правило изменения пути маршрута; nil — путь не меняется.
+func newPathRewrite(rc config.RouteConfig) (*proxy.PathRewrite, error) {
+	if !rc.StripPrefix && rc.Rewrite.Regex == "" {
+		return nil, nil
+	}
+	rw := &proxy.PathRewrite{Replacement: rc.Rewrite.Replacement}
+	if rc.StripPrefix {
+		rw.StripPrefix = rc.PathPrefix
+	}
+	if rc.Rewrite.Regex != "" {
+		re, err := regexp.Compile(rc.Rewrite.Regex)
+		if err != nil {
+			return nil, fmt.Errorf("invalid rewrite regex: %w", err)
+		}
+		rw.Regex = re
+	}
+	return rw, nil
+}
+
+// newHeaderPolicy строит правила заголовков; nil — заголовки не меняются.
+func newHeaderPolicy(request, response config.HeaderOpsConfig) (*proxy.HeaderPolicy, error) {
+	if isEmptyHeaderOps(request) && isEmptyHeaderOps(response) {
+		return nil, nil
+	}
+	return proxy.NewHeaderPolicy(
+		proxy.HeaderOps{Set: request.Set, Add: request.Add, Remove: request.Remove},
+		proxy.HeaderOps{Set: response.Set, Add: response.Add, Remove: response.Remove},
+	)
+}
+
+func isEmptyHeaderOps(ops config.HeaderOpsConfig) bool {
+	return len(ops.Set) == 0 && len(ops.Add) == 0 && len(ops.Remove) == 0
+}
+
+// proxyProtocolVersion переводит версию PROXY protocol из конфигурации в номер.
+func proxyProtocolVersion(version string) int {
+	if version == "v1" {
+		return proxyproto.V1
+	}
+	return proxyproto.V2
+}
+
+// newUpstreamTLS собирает TLS-конфигурацию соединений пула с бэкендами.
+// Пустые настройки дают nil: используется транспорт по умолчанию. Клиентский
+// сертификат возвращается отдельно, чтобы сервер перечитывал его с диска.
+func newUpstreamTLS(tc config.UpstreamTLSConfig, logger *zap.SugaredLogger) (*tls.Config, *certs.Store, error) {
+	if tc == (config.UpstreamTLSConfig{}) {
+		return nil, nil, nil
+	}
+	if tc.InsecureSkipVerify {
+		logger.Warn("tls.insecure_skip_verify is enabled: backend certificates are not verified")
+	}
+	var clientCert *certs.Store
+	if tc.CertFile != "" {
+		var err error
+		clientCert, err = certs.NewStore([]certs.Pair{{CertFile: tc.CertFile, KeyFile: tc.KeyFile}}, logger)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	cfg, err := certs.ClientConfig(tc.CAFile, tc.ServerName, clientCert, tc.InsecureSkipVerify)
+	if err != nil {
+		return nil, nil, err
+	}
+	return cfg, clientCert, nil
+}
@@ -0,0 +1,26 @@
+package server
+
+import (
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// redirectToHTTPS перенаправляет запрос на тот же адрес по HTTPS. 308
+// сохраняет метод и тело запроса.
+func redirectToHTTPS(tlsPort int) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		if strings.Contains(host, ":") {
+			host = "[" + host + "]" // IPv6
+		}
+		if tlsPort != 443 {
+			host += ":" + strconv.Itoa(tlsPort)
+		}
+		http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), http.StatusPermanentRedirect)
+	})
+}
@@ -2,6 +2,9 @@ package server
 
 import (
 	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
 	"net/http"
 	"strconv"
 	"time"
@@ -11,17 +14,34 @@ import (
 	"github.com/gorilla/mux"
 	"github.com/mk/loadBalancer/internal/api"
 	"github.com/mk/loadBalancer/internal/balancer"
+	"github.com/mk/loadBalancer/internal/certs"
+	"github.com/mk/loadBalancer/internal/clientip"
 	"github.com/mk/loadBalancer/internal/config"
 	"github.com/mk/loadBalancer/internal/proxy"
+	"github.com/mk/loadBalancer/internal/proxyproto"
 	"github.com/mk/loadBalancer/internal/ratelimiter"
 	"github.com/mk/loadBalancer/internal/storage"
 	"go.uber.org/zap"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
 )
 
 // Server представляет HTTP-сервер приложения
 type Server struct {
-	httpServer *http.Server
-	logger     *zap.SugaredLogger
+	httpServer     *http.Server       // при включённом TLS принимает HTTPS
+	redirectServer *http.Server       // перенаправляет HTTP на HTTPS (nil — выключен)
+	logger         *zap.SugaredLogger // логгер сервера
+	stopChecker    context.CancelFunc // останавливает health checker и перечитывание сертификатов при Shutdown
+	transports     []idleCloser       // соединения с бэкендами, закрываются при Shutdown
+	tunnels        *proxy.Tunnels     // WebSocket/Upgrade-соединения, закрываются при Shutdown
+
+	wrapListener func(net.Listener) net.Listener // разбор PROXY protocol на входящих соединениях (nil — выключен)
+}
+
+// idleCloser — транспорт до бэкендов (HTTP/1.1 или HTTP/2), простаивающие
+// соединения которого закрываются при Shutdown.
+type idleCloser interface {
+	CloseIdleConnections()
 }
 
 // New создает новый экземпляр Server
@@ -47,16 +67,156 @@ func New(appConfig *config.Config) (*Server, error) {
         sugarLogger,
     )
 
-    // Пул бекендов
-    backendPool := balancer.NewServerPool(appConfig.Backends)
+    // IP клиента одинаково определяют rate limiter и прокси
+    clientIPs, err := clientip.NewResolver(appConfig.TrustedProxies)
+    if err != nil {
+        sugarLogger.Errorf("Invalid trusted_proxies: %v", err)
+        return nil, err
+    }
+    rateLimiter.SetClientIPResolver(clientIPs)
+
+    // Проверенный клиентский сертификат заменяет X-Client-ID в rate limiter;
+    // его поля передаются бэкендам в заголовках, присланные клиентом значения отбрасываются
+    var certHeaders map[string]string
+    if tc := appConfig.TLS; tc.Enabled {
+        for header, field := range tc.ClientAuth.Headers {
+            if !certs.ValidField(field) {
+                err := fmt.Errorf("tls.client_auth.headers: unknown certificate field %q for %s", field, header)
+                sugarLogger.Error(err)
+                return nil, err
+            }
+        }
+        certHeaders = tc.ClientAuth.Headers
+        if identity := tc.ClientAuth.Identity; tc.ClientAuth.Mode != "none" {
+            if !certs.ValidField(identity) {
+                err := fmt.Errorf("tls.client_auth.identity: unknown certificate field %q", identity)
+                sugarLogger.Error(err)
+                return nil, err
+            }
+            rateLimiter.SetClientIdentity(func(r *http.Request) string {
+                return certs.ClientIdentity(r, identity)
+            })
+        }
+    }
 
-    // Стратегия балансировки
-    strategy, err := balancer.StrategyFactory(appConfig.Strategy)
+    // Общие для всех пулов параметры проксирования
+    tc := appConfig.Transport
+    transportConfig := proxy.TransportConfig{
+        MaxIdleConns:          tc.MaxIdleConns,
+        MaxIdleConnsPerHost:   tc.MaxIdleConnsPerHost,
+        IdleConnTimeout:       tc.IdleConnTimeout,
+        DialTimeout:           tc.DialTimeout,
+        KeepAlive:             tc.KeepAlive,
+        TLSHandshakeTimeout:   tc.TLSHandshakeTimeout,
+        ResponseHeaderTimeout: tc.ResponseHeaderTimeout,
+        DisableKeepAlives:     tc.DisableKeepAlives,
+        HTTP2:                 !tc.DisableHTTP2,
+    }
+    transport := proxy.NewTransport(transportConfig)
+    transports := []idleCloser{transport}
+    var sticky *proxy.StickySessions
+    if appConfig.StickySessions.Enabled {
+        if appConfig.StickySessions.SigningKey == "" {
+            sugarLogger.Warn("sticky_sessions.signing_key is empty, using a random key: cookies will not survive a restart")
+        }
+        sticky, err = proxy.NewStickySessions(
+            appConfig.StickySessions.CookieName,
+            appConfig.StickySessions.TTL,
+            appConfig.StickySessions.SigningKey,
+        )
+        if err != nil {
+            sugarLogger.Errorf("Failed to initialize sticky sessions: %v", err)
+            return nil, err
+        }
+    }
+    tunnels := proxy.NewTunnels(appConfig.Upgrades.IdleTimeout, appConfig.Upgrades.MaxLifetime, sugarLogger)
+    // Хеджирование расходует собственный бюджет, отдельный от бюджета повторов
+    var hedgeBudget *balancer.RetryBudget
+    if budget := appConfig.Hedging.Budget; budget.Percent > 0 || budget.MinPerSecond > 0 {
+        hedgeBudget = balancer.NewRetryBudget(budget.Percent, budget.MinPerSecond, budget.Window)
+    }
+
+    // Сертификаты TLS-листенера и клиентские сертификаты пулов перечитываются с диска
+    var certStores []*certs.Store
+
+    // Пулы бэкендов: у каждого своя стратегия, health checker и обработчик прокси
+    handlers := make(map[string]http.Handler, len(appConfig.Pools))
+    checkers := make([]*balancer.Checker, 0, len(appConfig.Pools))
+    for name, poolConfig := range appConfig.Pools {
+        poolLogger := sugarLogger.With("pool", name)
+        backendPool, err := newPool(appConfig, poolConfig, poolLogger)
+        if err != nil {
+            sugarLogger.Errorf("Failed to create pool %s: %v", name, err)
+            return nil, err
+        }
+        checker := newChecker(appConfig, poolConfig, backendPool)
+        checkers = append(checkers, checker)
+
+        proxyHandler := proxy.NewProxyHandler(backendPool, rateLimiter, poolLogger)
+        proxyHandler.Transport = transport
+        // Пулу с PROXY protocol, собственными настройками TLS или протоколом нужен свой транспорт.
+        // Health checks идут через него же: с тем же TLS и с заголовком PROXY
+        // protocol без адресов (LOCAL).
+        poolTransport := transportConfig
+        if version := poolConfig.ProxyProtocol; version != "" {
+            poolTransport.ProxyProtocol = proxyProtocolVersion(version)
+        }
+        var clientCert *certs.Store
+        if poolTransport.TLS, clientCert, err = newUpstreamTLS(poolConfig.TLS, poolLogger); err != nil {
+            sugarLogger.Errorf("Invalid TLS settings for pool %s: %v", name, err)
+            return nil, err
+        }
+        if clientCert != nil {
+            certStores = append(certStores, clientCert)
+        }
+        var own interface {
+            http.RoundTripper
+            idleCloser
+        }
+        switch poolConfig.Protocol {
+        case proxy.ProtocolHTTP2, proxy.ProtocolH2C:
+            own = proxy.NewHTTP2Transport(poolTransport, poolConfig.Protocol == proxy.ProtocolH2C)
+            proxyHandler.GRPC = true
+        case proxy.ProtocolHTTP1:
+            poolTransport.HTTP2 = false
+        }
+        if own == nil && poolTransport != transportConfig {
+            own = proxy.NewTransport(poolTransport)
+        }
+        if own != nil {
+            transports = append(transports, own)
+            proxyHandler.Transport = own
+            checker.Client.Transport = own
+        }
+        // Cookie привязки своя у каждого пула; пул по умолчанию сохраняет прежнее имя
+        proxyHandler.Sticky = sticky
+        if sticky != nil && name != config.DefaultPoolName {
+            proxyHandler.Sticky = sticky.ForPool(name)
+        }
+        proxyHandler.ClientIP = clientIPs
+        proxyHandler.Tunnels = tunnels
+        proxyHandler.CertHeaders = certHeaders
+        if proxyHandler.Headers, err = newHeaderPolicy(poolConfig.RequestHeaders, poolConfig.ResponseHeaders); err != nil {
+            sugarLogger.Errorf("Invalid header rules for pool %s: %v", name, err)
+            return nil, err
+        }
+        proxyHandler.Retry = proxy.RetryPolicy{
+            MaxAttempts:   appConfig.Retries.MaxAttempts,
+            PerTryTimeout: appConfig.Retries.PerTryTimeout,
+            TotalTimeout:  appConfig.Retries.TotalTimeout,
+            MaxBodyBytes:  appConfig.Retries.MaxBodyBytes,
+        }
+        if hedging := appConfig.Hedging; len(hedging.Paths) > 0 {
+            proxyHandler.Hedging = proxy.NewHedger(hedging.Paths, hedging.Delay, hedging.Percentile, hedgeBudget)
+        }
+        handlers[name] = ratelimiter.RateLimitMiddleware(rateLimiter, sugarLogger)(proxyHandler)
+    }
+
+    routes, err := newRoutingTable(appConfig.Routes, handlers)
     if err != nil {
-        sugarLogger.Errorf("Failed to create balancing strategy: %v", err)
+        sugarLogger.Errorf("Failed to build routing table: %v", err)
         return nil, err
     }
-    backendPool.SetStrategy(strategy)
 
     // Настройка маршрутов
     router := mux.NewRouter()
@@ -66,33 +226,146 @@ func New(appConfig *config.Config) (*Server, error) {
     apiRouter := router.PathPrefix("/clients").Subrouter() // Это должно быть перед прокси маршрутом
     apiHandler.RegisterRoutes(apiRouter)
 
-    // 2. Настройка прокси для всех остальных запросов
-    proxyHandler := proxy.NewProxyHandler(backendPool, rateLimiter, sugarLogger)
-    wrappedHandler := ratelimiter.RateLimitMiddleware(rateLimiter, sugarLogger)(proxyHandler)
-    router.PathPrefix("/").Handler(wrappedHandler)
+    // 2. Остальные запросы распределяются по пулам таблицей маршрутизации
+    routes.Register(router)
 
     // Создаем HTTP сервер
     httpServer := &http.Server{
         Addr:    ":" + strconv.Itoa(appConfig.Port),
         Handler: router,
         ReadTimeout:  5 * time.Second,
-        WriteTimeout: 10 * time.Second,
+        WriteTimeout: 10 * time.Second, // с туннелей после ответа 101 прокси снимает таймауты
         IdleTimeout:  15 * time.Second,
     }
 
+    // HTTP/2 без TLS (h2c) принимается только по явной настройке: prior knowledge
+    // и Upgrade: h2c на листенере без TLS
+    h2Server := &http2.Server{MaxConcurrentStreams: appConfig.HTTP2.MaxConcurrentStreams}
+    if appConfig.HTTP2.H2C && !appConfig.TLS.Enabled {
+        httpServer.Handler = h2c.NewHandler(router, h2Server)
+    }
+
+    // TLS: сертификаты выбираются по SNI и перечитываются при изменении файлов
+    var redirectServer *http.Server
+    if tc := appConfig.TLS; tc.Enabled {
+        pairs := make([]certs.Pair, 0, len(tc.Certificates))
+        for _, cert := range tc.Certificates {
+            pairs = append(pairs, certs.Pair{CertFile: cert.CertFile, KeyFile: cert.KeyFile})
+        }
+        certStore, err := certs.NewStore(pairs, sugarLogger)
+        if err != nil {
+            sugarLogger.Errorf("Failed to load TLS certificates: %v", err)
+            return nil, err
+        }
+        certStores = append(certStores, certStore)
+        httpServer.TLSConfig, err = certs.ServerConfig(certStore, tc.MinVersion, tc.CipherSuites)
+        if err != nil {
+            sugarLogger.Errorf("Invalid TLS settings: %v", err)
+            return nil, err
+        }
+        if err := certs.ConfigureClientAuth(httpServer.TLSConfig, tc.ClientAuth.Mode, tc.ClientAuth.CAFile); err != nil {
+            sugarLogger.Errorf("Invalid tls.client_auth: %v", err)
+            return nil, err
+        }
+        // HTTP/2 договаривается через ALPN; WebSocket и Upgrade клиенты
+        // по-прежнему открывают отдельным соединением HTTP/1.1
+        if appConfig.HTTP2.Disabled {
+            httpServer.TLSNextProto = map[string]func(*http.Server, *tls.Conn, http.Handler){}
+        } else if err := http2.ConfigureServer(httpServer, h2Server); err != nil {
+            sugarLogger.Errorf("Failed to enable HTTP/2: %v", err)
+            return nil, err
+        }
+        httpServer.Addr = ":" + strconv.Itoa(tc.Port)
+        if tc.RedirectHTTP {
+            redirectServer = &http.Server{
+                Addr:         ":" + strconv.Itoa(appConfig.Port),
+                Handler:      redirectToHTTPS(tc.Port),
+                ReadTimeout:  5 * time.Second,
+                WriteTimeout: 5 * time.Second,
+                IdleTimeout:  15 * time.Second,
+            }
+        }
+    }
+
+    // PROXY protocol принимается только от доверенных L4-балансировщиков;
+    // адрес клиента из заголовка становится RemoteAddr запроса
+    var wrapListener func(net.Listener) net.Listener
+    if pp := appConfig.ProxyProtocol; pp.Enabled {
+        trusted, err := clientip.NewResolver(pp.Trusted)
+        if err != nil {
+            sugarLogger.Errorf("Invalid proxy_protocol.trusted: %v", err)
+            return nil, err
+        }
+        wrapListener = func(ln net.Listener) net.Listener {
+            return proxyproto.NewListener(ln, trusted.IsTrusted, pp.HeaderTimeout)
+        }
+    }
+
+    // Health checker работает всё время жизни сервера и останавливается в Shutdown
+    checkerCtx, stopChecker := context.WithCancel(context.Background())
+    for _, checker := range checkers {
+        go checker.Run(checkerCtx)
+    }
+    for _, store := range certStores {
+        go store.Watch(checkerCtx, appConfig.TLS.ReloadInterval)
+    }
+
     return &Server{
-        httpServer: httpServer,
-        logger:     sugarLogger,
+        httpServer:     httpServer,
+        redirectServer: redirectServer,
+        logger:         sugarLogger,
+        stopChecker:    stopChecker,
+        transports:     transports,
+        tunnels:        tunnels,
+        wrapListener:   wrapListener,
     }, nil
 }
 
-
-// Start запускает сервер
+// Start запускает сервер и блокируется до его остановки. При включённом TLS
+// работают два листенера: HTTPS и, если задан redirect_http, HTTP с
+// перенаправлением. Если один из них упал, второй закрывается: без HTTPS
+// перенаправление ведёт в никуда, а без перенаправления сервер работал бы
+// лишь наполовину.
 func (s *Server) Start() error {
-	s.logger.Infof("Server starting on %s", s.httpServer.Addr)
-	if err := s.httpServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-		s.logger.Errorf("Server failed: %v", err)
-		return err
+	servers := []*http.Server{s.httpServer}
+	if s.redirectServer != nil {
+		servers = append(servers, s.redirectServer)
+	}
+	listeners := make([]net.Listener, 0, len(servers))
+	for _, srv := range servers {
+		ln, err := net.Listen("tcp", srv.Addr)
+		if err != nil {
+			for _, opened := range listeners {
+				opened.Close()
+			}
+			s.logger.Errorf("Server failed: %v", err)
+			return err
+		}
+		if s.wrapListener != nil {
+			ln = s.wrapListener(ln)
+		}
+		listeners = append(listeners, ln)
+	}
+
+	errs := make(chan error, len(servers))
+	for i, srv := range servers {
+		ln := listeners[i]
+		if srv.TLSConfig != nil {
+			s.logger.Infof("Server starting on %s (HTTPS)", srv.Addr)
+			go func() { errs <- srv.ServeTLS(ln, "", "") }()
+		} else {
+			s.logger.Infof("Server starting on %s", srv.Addr)
+			go func() { errs <- srv.Serve(ln) }()
+		}
+	}
+	for range servers {
+		if err := <-errs; err != nil && err != http.ErrServerClosed {
+			s.logger.Errorf("Server failed: %v", err)
+			for _, srv := range servers {
+				srv.Close()
+			}
+			return err
+		}
 	}
 	return nil
 }
@@ -100,10 +373,24 @@ func (s *Server) Start() error {
 // Shutdown корректно останавливает сервер
 func (s *Server) Shutdown(ctx context.Context) error {
 	s.logger.Info("Server shutting down")
-	if err := s.httpServer.Shutdown(ctx); err != nil {
+	s.stopChecker()
+	err := s.httpServer.Shutdown(ctx)
+	if s.redirectServer != nil {
+		if redirectErr := s.redirectServer.Shutdown(ctx); err == nil {
+			err = redirectErr
+		}
+	}
+	// Соединения после смены протокола http.Server не отслеживает: закрываем их сами
+	if tunnelsErr := s.tunnels.Shutdown(ctx); err == nil {
+		err = tunnelsErr
+	}
+	if err != nil {
 		s.logger.Errorf("Server shutdown error: %v", err)
 		return err
 	}
+	for _, transport := range s.transports {
+		transport.CloseIdleConnections()
+	}
 	s.logger.Info("Server stopped")
 	return nil
 }
@@ -4,6 +4,7 @@ import (
 	"context"
 	"net/http"
 	"net/http/httptest"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -49,3 +50,68 @@ func TestHealthCheckerIntegration(t *testing.T) {
 		t.Errorf("Expected deadServer to be dead")
 	}
 }
+
+func TestHealthCheckerRecovery(t *testing.T) {
+	// Сервер, который начинает отвечать 200 только после переключения флага
+	var healthy atomic.Bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/status" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if healthy.Load() {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	backend := balancer.NewBackend(server.URL)
+	checker := balancer.NewChecker([]*balancer.Backend{backend}, 50*time.Millisecond)
+	checker.Path = "/status"
+	checker.ExpectedStatus = http.StatusNoContent
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go checker.Run(ctx)
+
+	time.Sleep(150 * time.Millisecond)
+	if backend.IsAlive() {
+		t.Fatalf("Expected backend to be marked dead")
+	}
+
+	healthy.Store(true)
+	time.Sleep(150 * time.Millisecond)
+	if !backend.IsAlive() {
+		t.Errorf("Expected backend to rejoin the pool after recovery")
+	}
+}
+
+func TestPoolCheckerSeesAddedBackends(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	pool := balancer.NewServerPool([]string{server.URL})
+	checker := balancer.NewPoolChecker(pool, 20*time.Millisecond)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go checker.Run(ctx)
+
+	// Бэкенд, добавленный после запуска, проверяется со следующего тика
+	pool.AddBackend("http://127.0.0.1:65534")
+	added := pool.AllBackends()[1]
+	deadline := time.Now().Add(2 * time.Second)
+	for added.IsAlive() && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if added.IsAlive() {
+		t.Fatalf("Expected added dead backend to be health-checked and marked dead")
+	}
+	if !pool.AllBackends()[0].IsAlive() {
+		t.Errorf("Expected original backend to stay alive")
+	}
+}
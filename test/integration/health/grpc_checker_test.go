@@ -0,0 +1,84 @@
+package health
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/mk/loadBalancer/internal/balancer"
+	"github.com/mk/loadBalancer/internal/proxy"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+func TestGRPCHealthChecker(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := grpc.NewServer()
+	hs := health.NewServer()
+	healthpb.RegisterHealthServer(srv, hs)
+	go srv.Serve(ln)
+	defer srv.Stop()
+	hs.SetServingStatus("users", healthpb.HealthCheckResponse_SERVING)
+
+	backend := balancer.NewBackend("http://" + ln.Addr().String())
+	backend.SetHealthPolicy(balancer.HealthPolicy{FallThreshold: 1, RiseThreshold: 1})
+	checker := balancer.NewChecker([]*balancer.Backend{backend}, 50*time.Millisecond)
+	checker.Client.Transport = proxy.NewHTTP2Transport(proxy.DefaultTransportConfig(), true)
+	checker.GRPC = true
+	checker.GRPCService = "users"
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go checker.Run(ctx)
+
+	time.Sleep(150 * time.Millisecond)
+	if !backend.IsAlive() {
+		t.Fatalf("Expected SERVING backend to be alive: %+v", backend.HealthStatus())
+	}
+
+	// NOT_SERVING выводит бэкенд из пула, хотя сервер отвечает
+	hs.SetServingStatus("users", healthpb.HealthCheckResponse_NOT_SERVING)
+	time.Sleep(150 * time.Millisecond)
+	if backend.IsAlive() {
+		t.Fatalf("Expected NOT_SERVING backend to be marked dead")
+	}
+
+	hs.SetServingStatus("users", healthpb.HealthCheckResponse_SERVING)
+	time.Sleep(150 * time.Millisecond)
+	if !backend.IsAlive() {
+		t.Errorf("Expected backend to rejoin the pool after recovery")
+	}
+}
+
+func TestGRPCHealthCheckerUnknownService(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := grpc.NewServer()
+	healthpb.RegisterHealthServer(srv, health.NewServer())
+	go srv.Serve(ln)
+	defer srv.Stop()
+
+	backend := balancer.NewBackend("http://" + ln.Addr().String())
+	backend.SetHealthPolicy(balancer.HealthPolicy{FallThreshold: 1, RiseThreshold: 1})
+	checker := balancer.NewChecker([]*balancer.Backend{backend}, 50*time.Millisecond)
+	checker.Client.Transport = proxy.NewHTTP2Transport(proxy.DefaultTransportConfig(), true)
+	checker.GRPC = true
+	checker.GRPCService = "orders"
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go checker.Run(ctx)
+
+	// Сервис не зарегистрирован: grpc-status NOT_FOUND приходит в заголовках ответа
+	time.Sleep(150 * time.Millisecond)
+	if backend.IsAlive() {
+		t.Fatalf("Expected backend with unknown service to be marked dead")
+	}
+}
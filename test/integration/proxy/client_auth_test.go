@@ -0,0 +1,129 @@
+package proxy
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"testing"
+
+	"github.com/mk/loadBalancer/internal/certs"
+	"github.com/mk/loadBalancer/internal/certs/certstest"
+	"github.com/mk/loadBalancer/internal/proxy"
+	"go.uber.org/zap"
+)
+
+// startClientAuthProxy запускает прокси за TLS-листенером с проверкой
+// клиентских сертификатов по caFile и возвращает адрес листенера.
+func startClientAuthProxy(t *testing.T, mode, caFile string, h *proxy.ProxyHandler) string {
+	t.Helper()
+	store, err := certs.NewStore([]certs.Pair{certstest.WriteCert(t, t.TempDir(), "server", "lb.example.com")}, zap.NewNop().Sugar())
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := certs.ServerConfig(store, "1.2", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := certs.ConfigureClientAuth(cfg, mode, caFile); err != nil {
+		t.Fatal(err)
+	}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := &http.Server{Handler: h}
+	go srv.Serve(tls.NewListener(ln, cfg))
+	t.Cleanup(func() { srv.Close() })
+	return ln.Addr().String()
+}
+
+// clientCertRequest отправляет запрос с клиентским сертификатом (если задан)
+// и возвращает заголовки, полученные бэкендом.
+func clientCertRequest(t *testing.T, addr string, client *certs.Pair, headers map[string]string) (http.Header, error) {
+	t.Helper()
+	cfg := &tls.Config{ServerName: "lb.example.com", InsecureSkipVerify: true}
+	if client != nil {
+		cert, err := tls.LoadX509KeyPair(client.CertFile, client.KeyFile)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+	req, _ := http.NewRequest(http.MethodGet, fmt.Sprintf("https://%s/", addr), nil)
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := (&http.Client{Transport: &http.Transport{TLSClientConfig: cfg}}).Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	var upstream http.Header
+	if err := json.NewDecoder(resp.Body).Decode(&upstream); err != nil {
+		t.Fatalf("decode upstream headers: %v", err)
+	}
+	return upstream, nil
+}
+
+func newCertHeadersProxy(t *testing.T) *proxy.ProxyHandler {
+	t.Helper()
+	h := newProxy(t, headerEcho(t))
+	h.CertHeaders = map[string]string{
+		"X-Client-Cert-CN":          certs.FieldCN,
+		"X-Client-Cert-DNS":         certs.FieldSANDNS,
+		"X-Client-Cert-Fingerprint": certs.FieldFingerprint,
+	}
+	return h
+}
+
+func TestClientCertRequired(t *testing.T) {
+	client := certstest.WriteCert(t, t.TempDir(), "client", "billing", "billing.internal")
+	addr := startClientAuthProxy(t, "require", client.CertFile, newCertHeadersProxy(t))
+
+	upstream, err := clientCertRequest(t, addr, &client, map[string]string{"X-Client-Cert-CN": "admin"})
+	if err != nil {
+		t.Fatalf("request with client certificate: %v", err)
+	}
+	if got := upstream.Get("X-Client-Cert-CN"); got != "billing" {
+		t.Fatalf("Expected verified CN billing, got %q", got)
+	}
+	if got := upstream.Get("X-Client-Cert-DNS"); got != "billing,billing.internal" {
+		t.Fatalf("Expected SAN DNS names, got %q", got)
+	}
+	if got := upstream.Get("X-Client-Cert-Fingerprint"); len(got) != 64 {
+		t.Fatalf("Expected SHA-256 fingerprint, got %q", got)
+	}
+
+	if _, err := clientCertRequest(t, addr, nil, nil); err == nil {
+		t.Fatal("Expected handshake without client certificate to fail")
+	}
+
+	// Сертификат, не выписанный доверенным CA, отклоняется
+	stranger := certstest.WriteCert(t, t.TempDir(), "stranger", "billing")
+	if _, err := clientCertRequest(t, addr, &stranger, nil); err == nil {
+		t.Fatal("Expected untrusted client certificate to be rejected")
+	}
+}
+
+func TestClientCertOptionalStripsSpoofedHeaders(t *testing.T) {
+	client := certstest.WriteCert(t, t.TempDir(), "client", "billing")
+	addr := startClientAuthProxy(t, "optional", client.CertFile, newCertHeadersProxy(t))
+
+	upstream, err := clientCertRequest(t, addr, nil, map[string]string{"X-Client-Cert-CN": "admin"})
+	if err != nil {
+		t.Fatalf("request without client certificate: %v", err)
+	}
+	if got := upstream.Values("X-Client-Cert-CN"); len(got) != 0 {
+		t.Fatalf("Expected spoofed certificate header to be removed, got %q", got)
+	}
+
+	upstream, err = clientCertRequest(t, addr, &client, nil)
+	if err != nil {
+		t.Fatalf("request with client certificate: %v", err)
+	}
+	if got := upstream.Get("X-Client-Cert-CN"); got != "billing" {
+		t.Fatalf("Expected verified CN billing, got %q", got)
+	}
+}
@@ -0,0 +1,83 @@
+package proxy
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/mk/loadBalancer/internal/clientip"
+)
+
+// forwardedHeaders проксирует запрос и возвращает заголовки, полученные бэкендом.
+func forwardedHeaders(t *testing.T, trusted []string, remote string, headers map[string]string) http.Header {
+	t.Helper()
+
+	h := newProxy(t, headerEcho(t))
+	resolver, err := clientip.NewResolver(trusted)
+	if err != nil {
+		t.Fatalf("NewResolver: %v", err)
+	}
+	h.ClientIP = resolver
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Host = "lb.example.com"
+	req.RemoteAddr = remote
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	resp := httptest.NewRecorder()
+	h.ServeHTTP(resp, req)
+
+	var upstream http.Header
+	if err := json.Unmarshal(resp.Body.Bytes(), &upstream); err != nil {
+		t.Fatalf("decode upstream headers: %v", err)
+	}
+	return upstream
+}
+
+func TestForwardingHeadersFromUntrustedPeer(t *testing.T) {
+	upstream := forwardedHeaders(t, nil, "203.0.113.5:1234", map[string]string{
+		"X-Real-IP":         "1.1.1.1",
+		"X-Forwarded-For":   "1.1.1.1",
+		"X-Forwarded-Proto": "https",
+		"X-Forwarded-Host":  "evil.example.com",
+		"Forwarded":         "for=1.1.1.1",
+	})
+
+	// Подделанные заголовки клиента отбрасываются
+	expect := map[string]string{
+		"X-Real-Ip":         "203.0.113.5",
+		"X-Forwarded-For":   "203.0.113.5",
+		"X-Forwarded-Proto": "http",
+		"X-Forwarded-Host":  "lb.example.com",
+		"Forwarded":         `for=203.0.113.5;host="lb.example.com";proto=http`,
+	}
+	for name, want := range expect {
+		if got := upstream.Get(name); got != want {
+			t.Errorf("%s: expected %q, got %q", name, want, got)
+		}
+	}
+}
+
+func TestForwardingHeadersFromTrustedProxy(t *testing.T) {
+	upstream := forwardedHeaders(t, []string{"10.0.0.0/8"}, "10.0.0.2:1234", map[string]string{
+		"X-Forwarded-For":   "198.51.100.7",
+		"X-Forwarded-Proto": "https",
+		"X-Forwarded-Host":  "shop.example.com",
+		"Forwarded":         `for=198.51.100.7;proto=https`,
+	})
+
+	expect := map[string]string{
+		"X-Real-Ip":         "198.51.100.7",
+		"X-Forwarded-For":   "198.51.100.7, 10.0.0.2",
+		"X-Forwarded-Proto": "https",
+		"X-Forwarded-Host":  "shop.example.com",
+		"Forwarded":         `for=198.51.100.7;proto=https, for=10.0.0.2;host="lb.example.com";proto=http`,
+	}
+	for name, want := range expect {
+		if got := upstream.Get(name); got != want {
+			t.Errorf("%s: expected %q, got %q", name, want, got)
+		}
+	}
+}
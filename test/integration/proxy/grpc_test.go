@@ -0,0 +1,267 @@
+package proxy
+
+import (
+	"context"
+	"crypto/tls"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/mk/loadBalancer/internal/certs"
+	"github.com/mk/loadBalancer/internal/certs/certstest"
+	"github.com/mk/loadBalancer/internal/proxy"
+	"go.uber.org/zap"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+)
+
+// grpcBackend запускает gRPC-сервер со стандартным сервисом health и
+// возвращает его адрес и управление статусами.
+func grpcBackend(t *testing.T, opts ...grpc.ServerOption) (string, *health.Server) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := grpc.NewServer(opts...)
+	hs := health.NewServer()
+	healthpb.RegisterHealthServer(srv, hs)
+	go srv.Serve(ln)
+	t.Cleanup(srv.Stop)
+	return ln.Addr().String(), hs
+}
+
+// newGRPCProxy создаёт прокси к gRPC-бэкенду, с которым говорит по h2c.
+func newGRPCProxy(t *testing.T, backendAddr string) *proxy.ProxyHandler {
+	t.Helper()
+	h := newProxyURLs(t, "http://"+backendAddr)
+	h.Transport = proxy.NewHTTP2Transport(proxy.DefaultTransportConfig(), true)
+	h.GRPC = true
+	return h
+}
+
+// startH2CServer запускает листенер с HTTP/2 без TLS. Таймауты сервера
+// короче потоков в тестах: прокси должен снимать их с потоковых вызовов.
+func startH2CServer(t *testing.T, h http.Handler) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := &http.Server{
+		Handler:      h2c.NewHandler(h, &http2.Server{}),
+		ReadTimeout:  200 * time.Millisecond,
+		WriteTimeout: 200 * time.Millisecond,
+	}
+	go srv.Serve(ln)
+	t.Cleanup(func() { srv.Close() })
+	return ln.Addr().String()
+}
+
+func healthClient(t *testing.T, addr string, creds credentials.TransportCredentials) healthpb.HealthClient {
+	t.Helper()
+	conn, err := grpc.NewClient(addr, grpc.WithTransportCredentials(creds))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return healthpb.NewHealthClient(conn)
+}
+
+func TestGRPCUnaryOverH2C(t *testing.T) {
+	backendAddr, hs := grpcBackend(t)
+	hs.SetServingStatus("users", healthpb.HealthCheckResponse_SERVING)
+	client := healthClient(t, startH2CServer(t, newGRPCProxy(t, backendAddr)), insecure.NewCredentials())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	resp, err := client.Check(ctx, &healthpb.HealthCheckRequest{Service: "users"})
+	if err != nil {
+		t.Fatalf("Check: %v", err)
+	}
+	if resp.Status != healthpb.HealthCheckResponse_SERVING {
+		t.Fatalf("Expected SERVING, got %v", resp.Status)
+	}
+
+	// Код ошибки gRPC приходит в трейлерах ответа
+	_, err = client.Check(ctx, &healthpb.HealthCheckRequest{Service: "unknown"})
+	if status.Code(err) != codes.NotFound {
+		t.Fatalf("Expected NotFound from trailers, got %v", err)
+	}
+}
+
+func TestGRPCServerStreamingOverH2C(t *testing.T) {
+	backendAddr, hs := grpcBackend(t)
+	h := newGRPCProxy(t, backendAddr)
+	h.Retry = proxy.RetryPolicy{TotalTimeout: 200 * time.Millisecond}
+	client := healthClient(t, startH2CServer(t, h), insecure.NewCredentials())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	stream, err := client.Watch(ctx, &healthpb.HealthCheckRequest{})
+	if err != nil {
+		t.Fatalf("Watch: %v", err)
+	}
+	first, err := stream.Recv()
+	if err != nil || first.Status != healthpb.HealthCheckResponse_SERVING {
+		t.Fatalf("Expected SERVING, got %v (%v)", first, err)
+	}
+	if got := h.BackendPool.AllBackends()[0].GetConnections(); got != 1 {
+		t.Fatalf("Expected stream to be counted as active connection, got %d", got)
+	}
+
+	// Поток живёт дольше таймаутов сервера и общего дедлайна, а сообщения приходят сразу, без буферизации
+	time.Sleep(400 * time.Millisecond)
+	sent := time.Now()
+	hs.SetServingStatus("", healthpb.HealthCheckResponse_NOT_SERVING)
+	next, err := stream.Recv()
+	if err != nil || next.Status != healthpb.HealthCheckResponse_NOT_SERVING {
+		t.Fatalf("Expected NOT_SERVING, got %v (%v)", next, err)
+	}
+	if elapsed := time.Since(sent); elapsed > 500*time.Millisecond {
+		t.Fatalf("Expected streamed message right away, got it after %v", elapsed)
+	}
+}
+
+func TestGRPCOverTLSListener(t *testing.T) {
+	backendAddr, _ := grpcBackend(t)
+	store, err := certs.NewStore([]certs.Pair{certstest.WriteCert(t, t.TempDir(), "server", "lb.example.com")}, zap.NewNop().Sugar())
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := certs.ServerConfig(store, "1.2", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := &http.Server{Handler: newGRPCProxy(t, backendAddr), TLSConfig: cfg}
+	if err := http2.ConfigureServer(srv, &http2.Server{}); err != nil {
+		t.Fatal(err)
+	}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go srv.ServeTLS(ln, "", "")
+	t.Cleanup(func() { srv.Close() })
+
+	creds := credentials.NewTLS(&tls.Config{ServerName: "lb.example.com", InsecureSkipVerify: true})
+	client := healthClient(t, ln.Addr().String(), creds)
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	resp, err := client.Check(ctx, &healthpb.HealthCheckRequest{})
+	if err != nil || resp.Status != healthpb.HealthCheckResponse_SERVING {
+		t.Fatalf("Expected SERVING over TLS, got %v (%v)", resp, err)
+	}
+}
+
+func TestGRPCUpstreamHTTP2OverTLS(t *testing.T) {
+	pair := certstest.WriteCert(t, t.TempDir(), "backend", "grpc.internal")
+	serverCreds, err := credentials.NewServerTLSFromFile(pair.CertFile, pair.KeyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	backendAddr, _ := grpcBackend(t, grpc.Creds(serverCreds))
+
+	h := newProxyURLs(t, "https://"+backendAddr)
+	transport := proxy.DefaultTransportConfig()
+	transport.TLS = clientConfig(t, pair.CertFile, "grpc.internal", nil, false)
+	h.Transport = proxy.NewHTTP2Transport(transport, false)
+	h.GRPC = true
+	client := healthClient(t, startH2CServer(t, h), insecure.NewCredentials())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	resp, err := client.Check(ctx, &healthpb.HealthCheckRequest{})
+	if err != nil || resp.Status != healthpb.HealthCheckResponse_SERVING {
+		t.Fatalf("Expected SERVING from TLS backend, got %v (%v)", resp, err)
+	}
+}
+
+func TestGRPCNoBackendsReturnsUnavailable(t *testing.T) {
+	h := newGRPCProxy(t, "127.0.0.1:1")
+	h.BackendPool.AllBackends()[0].SetAlive(false)
+	client := healthClient(t, startH2CServer(t, h), insecure.NewCredentials())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	_, err := client.Check(ctx, &healthpb.HealthCheckRequest{})
+	// Статус и сообщение балансировщика приходят в трейлерах, а не как HTTP 503
+	if st := status.Convert(err); st.Code() != codes.Unavailable || st.Message() != "no available backends" {
+		t.Fatalf("Expected Unavailable from the balancer, got %v", err)
+	}
+}
+
+func TestGRPCRoutingByServiceAndMethod(t *testing.T) {
+	checkAddr, _ := grpcBackend(t)
+	router := newRouter(
+		&proxy.Route{Name: "check", GRPCService: "grpc.health.v1.Health", GRPCMethod: "Check", Handler: newGRPCProxy(t, checkAddr)},
+		// Правило gRPC не перехватывает обычные запросы по тому же пути
+		&proxy.Route{Name: "web", PathPrefix: "/", Methods: []string{http.MethodGet}, Handler: namedPool(t, "web")},
+	)
+	addr := startH2CServer(t, router)
+	client := healthClient(t, addr, insecure.NewCredentials())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	resp, err := client.Check(ctx, &healthpb.HealthCheckRequest{})
+	if err != nil || resp.Status != healthpb.HealthCheckResponse_SERVING {
+		t.Fatalf("Expected SERVING via check route, got %v (%v)", resp, err)
+	}
+
+	// Метод без маршрута получает UNIMPLEMENTED, а не HTTP 404
+	stream, err := client.Watch(ctx, &healthpb.HealthCheckRequest{})
+	if err == nil {
+		_, err = stream.Recv()
+	}
+	if st := status.Convert(err); st.Code() != codes.Unimplemented || st.Message() != "no route for /grpc.health.v1.Health/Watch" {
+		t.Fatalf("Expected Unimplemented for unrouted method, got %v", err)
+	}
+
+	httpResp, err := http.Get("http://" + addr + "/grpc.health.v1.Health/Check")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(httpResp.Body)
+	httpResp.Body.Close()
+	if string(body) != "web" {
+		t.Fatalf("Expected plain GET to reach web pool, got %d %q", httpResp.StatusCode, body)
+	}
+}
+
+func TestGRPCContentTypeDoesNotBypassTotalTimeout(t *testing.T) {
+	stalled := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(2 * time.Second):
+		}
+	}))
+	t.Cleanup(stalled.Close)
+
+	// Пул не настроен на gRPC: заголовок клиента не снимает общий дедлайн
+	h := newProxy(t, stalled)
+	h.Retry = proxy.RetryPolicy{TotalTimeout: 100 * time.Millisecond}
+
+	req := httptest.NewRequest(http.MethodPost, "/grpc.health.v1.Health/Watch", nil)
+	req.Header.Set("Content-Type", "application/grpc")
+	rec := httptest.NewRecorder()
+	start := time.Now()
+	h.ServeHTTP(rec, req)
+
+	if got := rec.Header().Get("Grpc-Status"); got != strconv.Itoa(int(codes.DeadlineExceeded)) {
+		t.Fatalf("Expected DeadlineExceeded from the balancer, got grpc-status %q", got)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Total timeout was not applied, request took %v", elapsed)
+	}
+}
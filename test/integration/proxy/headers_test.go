@@ -0,0 +1,148 @@
+package proxy
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/mk/loadBalancer/internal/proxy"
+)
+
+// headerEcho запускает бэкенд, возвращающий заголовки запроса в JSON.
+func headerEcho(t *testing.T) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Server", "backend/1.0")
+		w.Header().Set("X-Internal-Trace", "abc")
+		json.NewEncoder(w).Encode(r.Header)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestHeaderRulesWithTemplates(t *testing.T) {
+	srv := headerEcho(t)
+	h := newProxy(t, srv)
+
+	var err error
+	h.Headers, err = proxy.NewHeaderPolicy(
+		proxy.HeaderOps{
+			Set:    map[string]string{"X-Env": "prod", "X-Client": "{client_ip}"},
+			Add:    map[string]string{"X-Via": "lb ({route})"},
+			Remove: []string{"X-Debug"},
+		},
+		proxy.HeaderOps{
+			Set:    map[string]string{"X-Backend": "{backend_host}", "X-Request-Id": "{request_id}"},
+			Remove: []string{"Server"},
+		},
+	)
+	if err != nil {
+		t.Fatalf("NewHeaderPolicy: %v", err)
+	}
+	routePolicy, err := proxy.NewHeaderPolicy(
+		proxy.HeaderOps{Set: map[string]string{"X-Env": "staging"}},
+		proxy.HeaderOps{Remove: []string{"X-Internal-Trace"}},
+	)
+	if err != nil {
+		t.Fatalf("NewHeaderPolicy: %v", err)
+	}
+	router := newRouter(&proxy.Route{Name: "api", HeaderPolicy: routePolicy, Handler: h})
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.RemoteAddr = "203.0.113.7:5555"
+	req.Header.Set("X-Debug", "1")
+	req.Header.Set("X-Request-Id", "req-42")
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+
+	var upstream http.Header
+	if err := json.Unmarshal(resp.Body.Bytes(), &upstream); err != nil {
+		t.Fatalf("decode upstream headers: %v", err)
+	}
+	// Правила маршрута применяются после правил пула
+	if got := upstream.Get("X-Env"); got != "staging" {
+		t.Errorf("Expected route rule to override X-Env, got %q", got)
+	}
+	if got := upstream.Get("X-Client"); got != "203.0.113.7" {
+		t.Errorf("Expected X-Client from template, got %q", got)
+	}
+	if got := upstream.Get("X-Via"); got != "lb (api)" {
+		t.Errorf("Expected X-Via with route name, got %q", got)
+	}
+	if upstream.Get("X-Debug") != "" {
+		t.Errorf("Expected X-Debug to be removed")
+	}
+
+	if got, want := resp.Header().Get("X-Backend"), srv.Listener.Addr().String(); got != want {
+		t.Errorf("Expected X-Backend %q, got %q", want, got)
+	}
+	if got := resp.Header().Get("X-Request-Id"); got != "req-42" {
+		t.Errorf("Expected client request id to be kept, got %q", got)
+	}
+	if resp.Header().Get("Server") != "" || resp.Header().Get("X-Internal-Trace") != "" {
+		t.Errorf("Expected response headers to be removed, got %v", resp.Header())
+	}
+}
+
+func TestHeaderRulesGenerateRequestID(t *testing.T) {
+	h := newProxy(t, headerEcho(t))
+	var err error
+	h.Headers, err = proxy.NewHeaderPolicy(
+		proxy.HeaderOps{Set: map[string]string{"X-Request-Id": "{request_id}"}},
+		proxy.HeaderOps{Set: map[string]string{"X-Request-Id": "{request_id}"}},
+	)
+	if err != nil {
+		t.Fatalf("NewHeaderPolicy: %v", err)
+	}
+
+	resp, body := doRequest(t, h, "/")
+	var upstream http.Header
+	if err := json.Unmarshal([]byte(body), &upstream); err != nil {
+		t.Fatalf("decode upstream headers: %v", err)
+	}
+	id := resp.Header().Get("X-Request-Id")
+	if len(id) != 32 || upstream.Get("X-Request-Id") != id {
+		t.Fatalf("Expected the same generated request id upstream and downstream, got %q and %q", upstream.Get("X-Request-Id"), id)
+	}
+}
+
+func TestHeaderRulesPathCannotInjectHeaders(t *testing.T) {
+	h := newProxy(t, headerEcho(t))
+	var err error
+	h.Headers, err = proxy.NewHeaderPolicy(
+		proxy.HeaderOps{Set: map[string]string{"X-Original-Path": "{path}"}},
+		proxy.HeaderOps{Set: map[string]string{"X-Path": "{path}"}},
+	)
+	if err != nil {
+		t.Fatalf("NewHeaderPolicy: %v", err)
+	}
+
+	// Декодированный путь содержит CR/LF: в заголовок попадает закодированный
+	resp, body := doRequest(t, h, "/a%0d%0aX-Injected:%20x")
+	if resp.Code != http.StatusOK {
+		t.Fatalf("Expected request to reach the backend, got %d", resp.Code)
+	}
+	var upstream http.Header
+	if err := json.Unmarshal([]byte(body), &upstream); err != nil {
+		t.Fatalf("decode upstream headers: %v", err)
+	}
+	if got := upstream.Get("X-Original-Path"); got != "/a%0d%0aX-Injected:%20x" {
+		t.Errorf("Expected escaped path upstream, got %q", got)
+	}
+	if upstream.Get("X-Injected") != "" {
+		t.Errorf("Expected no injected header upstream")
+	}
+	if got := resp.Header().Get("X-Path"); got != "/a%0d%0aX-Injected:%20x" {
+		t.Errorf("Expected escaped path downstream, got %q", got)
+	}
+}
+
+func TestHeaderRulesRejectUnknownVariable(t *testing.T) {
+	if _, err := proxy.NewHeaderPolicy(proxy.HeaderOps{Set: map[string]string{"X-A": "{nope}"}}, proxy.HeaderOps{}); err == nil {
+		t.Fatal("Expected error for unknown template variable")
+	}
+	if _, err := proxy.NewHeaderPolicy(proxy.HeaderOps{}, proxy.HeaderOps{Add: map[string]string{"X-A": "{client_ip"}}); err == nil {
+		t.Fatal("Expected error for unclosed template")
+	}
+}
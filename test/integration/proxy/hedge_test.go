@@ -0,0 +1,223 @@
+package proxy
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/mk/loadBalancer/internal/balancer"
+	"github.com/mk/loadBalancer/internal/proxy"
+)
+
+// slowCounters считает запросы к медленному бэкенду.
+type slowCounters struct {
+	hits      atomic.Int32
+	cancelled atomic.Int32
+}
+
+// slowBackend отвечает "slow" через delay и считает полученные и отменённые запросы.
+func slowBackend(t *testing.T, delay time.Duration, c *slowCounters) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c.hits.Add(1)
+		select {
+		case <-time.After(delay):
+			fmt.Fprint(w, "slow")
+		case <-r.Context().Done():
+			c.cancelled.Add(1)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestHedgingReturnsFastestResponse(t *testing.T) {
+	var c slowCounters
+	h := newProxy(t, slowBackend(t, time.Second, &c), startBackends(t, 1)[0])
+	h.Hedging = proxy.NewHedger([]string{"/search"}, 20*time.Millisecond, 0, nil)
+
+	// Round-robin отправляет половину запросов на медленный бэкенд
+	for i := 0; i < 4; i++ {
+		start := time.Now()
+		resp, body := doRequest(t, h, "/search?q=1")
+		if resp.Code != http.StatusOK || body != "backend-0" {
+			t.Fatalf("Request %d: expected hedged response from fast backend, got %d %q", i, resp.Code, body)
+		}
+		if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+			t.Fatalf("Request %d: expected hedging to cut latency, took %v", i, elapsed)
+		}
+	}
+
+	// Все проигравшие запросы к медленному бэкенду отменяются
+	if c.hits.Load() == 0 {
+		t.Fatal("Expected some requests to reach the slow backend")
+	}
+	deadline := time.Now().Add(time.Second)
+	for c.cancelled.Load() < c.hits.Load() && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if hits, cancelled := c.hits.Load(), c.cancelled.Load(); cancelled != hits {
+		t.Fatalf("Expected all %d requests on slow backend to be cancelled, got %d", hits, cancelled)
+	}
+}
+
+func TestHedgeRequestTargetsHedgeBackend(t *testing.T) {
+	var c slowCounters
+	slow := slowBackend(t, time.Second, &c)
+	var gotPath, gotBackend atomic.Value
+	fast := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath.Store(r.URL.Path)
+		gotBackend.Store(r.Header.Get("X-Backend"))
+		fmt.Fprint(w, "fast")
+	}))
+	t.Cleanup(fast.Close)
+
+	// У бэкендов разные базовые пути; первым выбирается медленный
+	h := newProxyURLs(t, slow.URL+"/slow", fast.URL+"/fast")
+	h.Hedging = proxy.NewHedger([]string{"/search"}, 20*time.Millisecond, 0, nil)
+	var err error
+	h.Headers, err = proxy.NewHeaderPolicy(
+		proxy.HeaderOps{Set: map[string]string{"X-Backend": "{backend}"}},
+		proxy.HeaderOps{Set: map[string]string{"X-Served-By": "{backend_host}"}},
+	)
+	if err != nil {
+		t.Fatalf("NewHeaderPolicy: %v", err)
+	}
+
+	resp, body := doRequest(t, h, "/search")
+	if body != "fast" {
+		t.Fatalf("Expected hedged response, got %d %q", resp.Code, body)
+	}
+	// Запрос хеджирования строится для своего бэкенда, а не копирует основной
+	if p := gotPath.Load(); p != "/fast/search" {
+		t.Errorf("Expected hedge request path /fast/search, got %v", p)
+	}
+	if b := gotBackend.Load(); b != fast.URL+"/fast" {
+		t.Errorf("Expected hedge request X-Backend %s/fast, got %v", fast.URL, b)
+	}
+	if got, want := resp.Header().Get("X-Served-By"), strings.TrimPrefix(fast.URL, "http://"); got != want {
+		t.Errorf("Expected X-Served-By %s for the winning hedge, got %s", want, got)
+	}
+}
+
+func TestHedgingOnlyForConfiguredPaths(t *testing.T) {
+	var c slowCounters
+	h := newProxy(t, slowBackend(t, 100*time.Millisecond, &c), startBackends(t, 1)[0])
+	h.Hedging = proxy.NewHedger([]string{"/search"}, 20*time.Millisecond, 0, nil)
+
+	if resp, body := doRequest(t, h, "/other"); resp.Code != http.StatusOK || body != "slow" {
+		t.Fatalf("Expected unhedged response from slow backend, got %d %q", resp.Code, body)
+	}
+}
+
+func TestHedgingBudget(t *testing.T) {
+	var c slowCounters
+	h := newProxy(t, slowBackend(t, 200*time.Millisecond, &c), startBackends(t, 1)[0])
+	// Бюджет допускает один хеджирующий запрос за окно
+	budget := balancer.NewRetryBudget(0, 0.1, 10*time.Second)
+	h.Hedging = proxy.NewHedger([]string{"/"}, 20*time.Millisecond, 0, budget)
+
+	slow := 0
+	for i := 0; i < 4; i++ {
+		if _, body := doRequest(t, h, "/"); body == "slow" {
+			slow++
+		}
+	}
+	// Из всех запросов к медленному бэкенду хеджируется только один
+	hits := int(c.hits.Load())
+	if hits < 2 {
+		t.Fatalf("Expected at least 2 requests on slow backend, got %d", hits)
+	}
+	if slow != hits-1 {
+		t.Fatalf("Expected %d unhedged slow responses, got %d", hits-1, slow)
+	}
+}
+
+func TestHedgingReportsLoserToPool(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(60 * time.Millisecond):
+			fmt.Fprint(w, "ok")
+		case <-r.Context().Done():
+		}
+	})
+	a, b := httptest.NewServer(handler), httptest.NewServer(handler)
+	t.Cleanup(a.Close)
+	t.Cleanup(b.Close)
+
+	h := newProxy(t, a, b)
+	h.Hedging = proxy.NewHedger([]string{"/search"}, 10*time.Millisecond, 0, nil)
+	h.BackendPool.SetCircuitBreakers(balancer.BreakerConfig{
+		MinRequests: 1, ErrorThreshold: 1, OpenTimeout: time.Millisecond, HalfOpenRequests: 1,
+	})
+
+	// Оба выключателя разомкнуты, после OpenTimeout каждый пропустит один пробный запрос
+	backends := h.BackendPool.AllBackends()
+	for _, backend := range backends {
+		h.BackendPool.Done(backend, balancer.OutcomeError, 0)
+	}
+	time.Sleep(5 * time.Millisecond)
+
+	if resp, body := doRequest(t, h, "/search"); body != "ok" {
+		t.Fatalf("Expected response, got %d %q", resp.Code, body)
+	}
+
+	// Победитель замыкает свой выключатель, а отменённый проигравший возвращает
+	// пробный запрос: бэкенд снова может его получить
+	deadline := time.Now().Add(time.Second)
+	for {
+		closed, ready := 0, 0
+		for _, backend := range backends {
+			switch {
+			case backend.BreakerState() == balancer.BreakerClosed:
+				closed++
+			case backend.IsAvailable():
+				ready++
+			}
+		}
+		if closed == 1 && ready == 1 {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected one closed breaker and one half-open accepting a trial, got %v and %v",
+				backends[0].BreakerState(), backends[1].BreakerState())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// brokenBackend через delay обрывает соединение без ответа и считает запросы.
+func brokenBackend(t *testing.T, delay time.Duration, hits *atomic.Int32) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		time.Sleep(delay)
+		if conn, _, err := http.NewResponseController(w).Hijack(); err == nil {
+			conn.Close()
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestRetryExcludesFailedHedgeBackend(t *testing.T) {
+	var primaryHits, hedgeHits atomic.Int32
+	h := newProxy(t, brokenBackend(t, 50*time.Millisecond, &primaryHits), brokenBackend(t, 0, &hedgeHits))
+	h.Hedging = proxy.NewHedger([]string{"/search"}, 10*time.Millisecond, 0, nil)
+	h.Retry = proxy.RetryPolicy{MaxAttempts: 3}
+
+	// Основной и хеджирующий запросы падают: для повтора бэкендов не остаётся
+	if resp, _ := doRequest(t, h, "/search"); resp.Code == http.StatusOK {
+		t.Fatalf("Expected request to fail, got %d", resp.Code)
+	}
+	if got := primaryHits.Load(); got != 1 {
+		t.Errorf("Expected primary backend to be tried once, got %d", got)
+	}
+	if got := hedgeHits.Load(); got != 1 {
+		t.Errorf("Expected failed hedge backend to be excluded from retries, got %d requests", got)
+	}
+}
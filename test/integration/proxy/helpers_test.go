@@ -0,0 +1,62 @@
+package proxy
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/mk/loadBalancer/internal/balancer"
+	"github.com/mk/loadBalancer/internal/proxy"
+	"go.uber.org/zap"
+)
+
+// startBackends запускает n тестовых бэкендов, отвечающих своим именем.
+func startBackends(t *testing.T, n int) []*httptest.Server {
+	t.Helper()
+
+	servers := make([]*httptest.Server, 0, n)
+	for i := 0; i < n; i++ {
+		name := fmt.Sprintf("backend-%d", i)
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprint(w, name)
+		}))
+		t.Cleanup(srv.Close)
+		servers = append(servers, srv)
+	}
+	return servers
+}
+
+// newProxy создаёт ProxyHandler над бэкендами с round-robin стратегией.
+func newProxy(t *testing.T, servers ...*httptest.Server) *proxy.ProxyHandler {
+	t.Helper()
+
+	urls := make([]string, 0, len(servers))
+	for _, srv := range servers {
+		urls = append(urls, srv.URL)
+	}
+	return newProxyURLs(t, urls...)
+}
+
+// newProxyURLs создаёт ProxyHandler над бэкендами, заданными URL.
+func newProxyURLs(t *testing.T, urls ...string) *proxy.ProxyHandler {
+	t.Helper()
+
+	pool := balancer.NewServerPool(urls)
+	pool.SetStrategy(balancer.NewRoundRobinStrategy())
+
+	return proxy.NewProxyHandler(pool, nil, zap.NewNop().Sugar())
+}
+
+// doRequest проксирует GET-запрос и возвращает ответ и тело.
+func doRequest(t *testing.T, h http.Handler, path string, cookies ...*http.Cookie) (*httptest.ResponseRecorder, string) {
+	t.Helper()
+
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	for _, c := range cookies {
+		req.AddCookie(c)
+	}
+	resp := httptest.NewRecorder()
+	h.ServeHTTP(resp, req)
+	return resp, resp.Body.String()
+}
@@ -0,0 +1,109 @@
+package proxy
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/mk/loadBalancer/internal/balancer"
+)
+
+func TestOutlierEjectionFromProxiedTraffic(t *testing.T) {
+	servers := startBackends(t, 2)
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer failing.Close()
+
+	h := newProxy(t, append(servers, failing)...)
+	h.BackendPool.SetOutlierDetector(balancer.NewOutlierDetector(balancer.OutlierConfig{
+		ConsecutiveGatewayErrors: 2,
+		BaseEjectionTime:         time.Minute,
+		MaxEjectionPercent:       50,
+	}))
+
+	// Round-robin дважды доходит до сбойного бэкенда за 6 запросов
+	for i := 0; i < 6; i++ {
+		doRequest(t, h, "/")
+	}
+
+	bad := h.BackendPool.AllBackends()[2]
+	if !bad.IsEjected() {
+		t.Fatalf("Expected failing backend to be ejected, status: %+v", bad.HealthStatus())
+	}
+
+	for i := 0; i < 10; i++ {
+		if resp, _ := doRequest(t, h, "/"); resp.Code != http.StatusOK {
+			t.Fatalf("Expected only healthy backends to serve after ejection, got %d", resp.Code)
+		}
+	}
+}
+
+// abortRequests отправляет через прокси запросы, которые клиент отменяет, не дождавшись ответа.
+func abortRequests(t *testing.T, h http.Handler, n int) {
+	t.Helper()
+	for i := 0; i < n; i++ {
+		// Как при разрыве соединения клиентом: контекст отменяется, а не истекает
+		ctx, cancel := context.WithCancel(context.Background())
+		timer := time.AfterFunc(30*time.Millisecond, cancel)
+		req := httptest.NewRequest(http.MethodGet, "/", nil).WithContext(ctx)
+		h.ServeHTTP(httptest.NewRecorder(), req)
+		timer.Stop()
+		cancel()
+	}
+}
+
+// stalledBackend отвечает только после ухода клиента или через секунду.
+func stalledBackend(t *testing.T) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(time.Second):
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestClientAbortDoesNotEjectBackend(t *testing.T) {
+	h := newProxy(t, stalledBackend(t), stalledBackend(t))
+	h.BackendPool.SetOutlierDetector(balancer.NewOutlierDetector(balancer.OutlierConfig{
+		ConsecutiveGatewayErrors: 2,
+		BaseEjectionTime:         time.Minute,
+		MaxEjectionPercent:       50,
+	}))
+
+	// Round-robin отдаёт каждому бэкенду по два прерванных запроса
+	abortRequests(t, h, 4)
+
+	for _, backend := range h.BackendPool.AllBackends() {
+		if backend.IsEjected() {
+			t.Fatalf("Expected backend to stay in the pool after client aborts, status: %+v", backend.HealthStatus())
+		}
+	}
+}
+
+func TestClientAbortDoesNotTripBreaker(t *testing.T) {
+	h := newProxy(t, stalledBackend(t))
+	h.BackendPool.SetCircuitBreakers(balancer.BreakerConfig{
+		MinRequests: 2, ErrorThreshold: 0.5, OpenTimeout: time.Millisecond, HalfOpenRequests: 1,
+	})
+	backend := h.BackendPool.AllBackends()[0]
+
+	abortRequests(t, h, 3)
+	if state := backend.BreakerState(); state != balancer.BreakerClosed {
+		t.Fatalf("Expected breaker to stay closed after client aborts, got %s", state)
+	}
+
+	// Прерванный клиентом пробный запрос не размыкает выключатель снова
+	h.BackendPool.Done(backend, balancer.OutcomeError, 0)
+	h.BackendPool.Done(backend, balancer.OutcomeError, 0)
+	time.Sleep(5 * time.Millisecond)
+	abortRequests(t, h, 1)
+	if state := backend.BreakerState(); state != balancer.BreakerHalfOpen {
+		t.Fatalf("Expected breaker to stay half-open after an aborted trial, got %s", state)
+	}
+}
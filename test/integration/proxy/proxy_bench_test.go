@@ -0,0 +1,79 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/http/httputil"
+	"net/url"
+	"sync/atomic"
+	"testing"
+
+	"github.com/mk/loadBalancer/internal/balancer"
+	"github.com/mk/loadBalancer/internal/proxy"
+	"go.uber.org/zap"
+)
+
+// benchmarkHandler гоняет GET-запросы через обработчик параллельно.
+func benchmarkHandler(b *testing.B, h http.Handler) {
+	// FailNow нельзя вызывать из горутин RunParallel: последний неверный код
+	// запоминается, а бенчмарк падает после их завершения
+	var badStatus atomic.Int64
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			resp := httptest.NewRecorder()
+			h.ServeHTTP(resp, req)
+			if resp.Code != http.StatusOK {
+				badStatus.Store(int64(resp.Code))
+			}
+		}
+	})
+	if code := badStatus.Load(); code != 0 {
+		b.Fatalf("expected 200, got %d", code)
+	}
+}
+
+func startBenchBackends(b *testing.B, n int) *balancer.ServerPool {
+	urls := make([]string, 0, n)
+	for i := 0; i < n; i++ {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte("ok"))
+		}))
+		b.Cleanup(srv.Close)
+		urls = append(urls, srv.URL)
+	}
+	pool := balancer.NewServerPool(urls)
+	pool.SetStrategy(balancer.NewRoundRobinStrategy())
+	return pool
+}
+
+// BenchmarkProxyHandler — общий ReverseProxy и настроенный транспорт.
+func BenchmarkProxyHandler(b *testing.B) {
+	pool := startBenchBackends(b, 3)
+	h := proxy.NewProxyHandler(pool, nil, zap.NewNop().Sugar())
+	benchmarkHandler(b, h)
+}
+
+// BenchmarkPerRequestReverseProxy — прежняя схема для сравнения: ReverseProxy
+// и director создаются на каждый запрос, соединения идут через http.DefaultTransport.
+func BenchmarkPerRequestReverseProxy(b *testing.B) {
+	pool := startBenchBackends(b, 3)
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		backend := pool.NextBackend()
+		targetURL, _ := url.Parse(backend.URL)
+		rp := httputil.NewSingleHostReverseProxy(targetURL)
+		director := rp.Director
+		rp.Director = func(req *http.Request) {
+			director(req)
+			req.Host = targetURL.Host
+			req.Header.Set("X-Real-IP", "192.0.2.1")
+			req.Header.Set("X-Forwarded-For", "192.0.2.1")
+		}
+		backend.IncConnections()
+		defer backend.DecConnections()
+		rp.ServeHTTP(w, r)
+	})
+	benchmarkHandler(b, h)
+}
@@ -0,0 +1,73 @@
+package proxy
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/mk/loadBalancer/internal/proxy"
+	"github.com/mk/loadBalancer/internal/proxyproto"
+)
+
+// remoteAddrEcho запускает бэкенд, принимающий PROXY protocol и отвечающий
+// адресом клиента, который он увидел.
+func remoteAddrEcho(t *testing.T) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.RemoteAddr))
+	}))
+	srv.Listener = proxyproto.NewListener(srv.Listener, nil, 0)
+	srv.Start()
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestProxyProtocolTowardBackends(t *testing.T) {
+	for _, version := range []int{proxyproto.V1, proxyproto.V2} {
+		h := newProxy(t, remoteAddrEcho(t))
+		cfg := proxy.DefaultTransportConfig()
+		cfg.ProxyProtocol = version
+		h.Transport = proxy.NewTransport(cfg)
+
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.RemoteAddr = "203.0.113.9:4444"
+		resp := httptest.NewRecorder()
+		h.ServeHTTP(resp, req)
+
+		if body := resp.Body.String(); body != "203.0.113.9:4444" {
+			t.Fatalf("v%d: expected backend to see client address, got %d %q", version, resp.Code, body)
+		}
+	}
+}
+
+func TestProxyProtocolOnListener(t *testing.T) {
+	srv := remoteAddrEcho(t)
+
+	conn, err := net.Dial("tcp", srv.Listener.Addr().String())
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer conn.Close()
+
+	src := &net.TCPAddr{IP: net.ParseIP("198.51.100.20"), Port: 5000}
+	if err := proxyproto.WriteHeader(conn, proxyproto.V2, src); err != nil {
+		t.Fatalf("WriteHeader: %v", err)
+	}
+	req, _ := http.NewRequest(http.MethodGet, "http://lb/", nil)
+	if err := req.Write(conn); err != nil {
+		t.Fatalf("write request: %v", err)
+	}
+	resp, err := http.ReadResponse(bufio.NewReader(conn), req)
+	if err != nil {
+		t.Fatalf("read response: %v", err)
+	}
+	defer resp.Body.Close()
+
+	buf := make([]byte, 64)
+	n, _ := resp.Body.Read(buf)
+	if got := string(buf[:n]); got != src.String() {
+		t.Fatalf("Expected RemoteAddr from PROXY header %s, got %q", src, got)
+	}
+}
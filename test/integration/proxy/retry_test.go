@@ -0,0 +1,144 @@
+package proxy
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mk/loadBalancer/internal/balancer"
+	"github.com/mk/loadBalancer/internal/proxy"
+)
+
+// deadBackend возвращает URL, на котором никто не слушает.
+func deadBackend(t *testing.T) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.NotFoundHandler())
+	srv.Close()
+	return srv
+}
+
+func TestRetryIdempotentRequestOnAnotherBackend(t *testing.T) {
+	h := newProxy(t, deadBackend(t), startBackends(t, 1)[0])
+	h.Retry = proxy.RetryPolicy{MaxAttempts: 2}
+
+	// Round-robin чередует бэкенды: каждый второй запрос сначала попадает на мёртвый
+	for i := 0; i < 4; i++ {
+		if resp, body := doRequest(t, h, "/"); resp.Code != http.StatusOK || body != "backend-0" {
+			t.Fatalf("Request %d: expected retry to succeed, got %d %q", i, resp.Code, body)
+		}
+	}
+}
+
+func TestNoRetryForNonIdempotentRequest(t *testing.T) {
+	h := newProxy(t, deadBackend(t), startBackends(t, 1)[0])
+	h.Retry = proxy.RetryPolicy{MaxAttempts: 3}
+
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader("payload"))
+	resp := httptest.NewRecorder()
+	h.ServeHTTP(resp, req)
+
+	if resp.Code != http.StatusBadGateway {
+		t.Fatalf("Expected POST to fail without retry, got %d", resp.Code)
+	}
+}
+
+func TestRetryReplaysBodyWithIdempotencyKey(t *testing.T) {
+	echo := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		w.Write(body)
+	}))
+	defer echo.Close()
+
+	h := newProxy(t, deadBackend(t), echo)
+	h.Retry = proxy.RetryPolicy{MaxAttempts: 2}
+
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader("payload"))
+	req.Header.Set(proxy.IdempotencyKeyHeader, "key-1")
+	resp := httptest.NewRecorder()
+	h.ServeHTTP(resp, req)
+
+	if resp.Code != http.StatusOK || resp.Body.String() != "payload" {
+		t.Fatalf("Expected body to be replayed on retry, got %d %q", resp.Code, resp.Body.String())
+	}
+}
+
+func TestRetryBodyOverLimitIsNotRetried(t *testing.T) {
+	h := newProxy(t, deadBackend(t), startBackends(t, 1)[0])
+	h.Retry = proxy.RetryPolicy{MaxAttempts: 2, MaxBodyBytes: 4}
+
+	req := httptest.NewRequest(http.MethodPut, "/", strings.NewReader("too large body"))
+	req.Header.Set(proxy.IdempotencyKeyHeader, "key-2")
+	resp := httptest.NewRecorder()
+	h.ServeHTTP(resp, req)
+
+	if resp.Code != http.StatusBadGateway {
+		t.Fatalf("Expected request with body over limit not to be retried, got %d", resp.Code)
+	}
+}
+
+func TestRetryPerTryTimeout(t *testing.T) {
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(time.Second):
+		case <-r.Context().Done():
+		}
+		w.Write([]byte("slow"))
+	}))
+	defer slow.Close()
+
+	h := newProxy(t, slow, startBackends(t, 1)[0])
+	h.Retry = proxy.RetryPolicy{MaxAttempts: 2, PerTryTimeout: 50 * time.Millisecond}
+
+	start := time.Now()
+	resp, body := doRequest(t, h, "/")
+	if resp.Code != http.StatusOK || body != "backend-0" {
+		t.Fatalf("Expected slow backend to be abandoned, got %d %q", resp.Code, body)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("Per-try timeout was not applied, request took %v", elapsed)
+	}
+
+	// Без запасных бэкендов клиент получает 504
+	only := newProxy(t, slow)
+	only.Retry = proxy.RetryPolicy{MaxAttempts: 2, PerTryTimeout: 50 * time.Millisecond}
+	if resp, _ := doRequest(t, only, "/"); resp.Code != http.StatusGatewayTimeout {
+		t.Fatalf("Expected 504 after per-try timeout, got %d", resp.Code)
+	}
+}
+
+func TestRetryCountHeader(t *testing.T) {
+	h := newProxy(t, deadBackend(t), startBackends(t, 1)[0])
+	h.Retry = proxy.RetryPolicy{MaxAttempts: 2}
+
+	// Первый запрос попадает на мёртвый бэкенд и обслуживается с повтора
+	resp, _ := doRequest(t, h, "/")
+	if got := resp.Header().Get(proxy.RetryCountHeader); got != "1" {
+		t.Fatalf("Expected %s: 1 on retried response, got %q", proxy.RetryCountHeader, got)
+	}
+
+	// Без мёртвого бэкенда в выборе ответ приходит с первой попытки
+	h.BackendPool.AllBackends()[0].SetAlive(false)
+	resp, _ = doRequest(t, h, "/")
+	if got := resp.Header().Get(proxy.RetryCountHeader); got != "0" {
+		t.Fatalf("Expected %s: 0 on first-try response, got %q", proxy.RetryCountHeader, got)
+	}
+}
+
+func TestRetryBudgetFailsFast(t *testing.T) {
+	h := newProxy(t, deadBackend(t), startBackends(t, 1)[0])
+	h.Retry = proxy.RetryPolicy{MaxAttempts: 2}
+	h.BackendPool.SetRetryBudget(balancer.NewRetryBudget(0, 0.5, 2*time.Second))
+
+	// Минимальная квота — один повтор за окно. Round-robin отправляет на мёртвый
+	// бэкенд первую попытку каждого запроса, повтор уходит на живой.
+	if resp, _ := doRequest(t, h, "/"); resp.Code != http.StatusOK {
+		t.Fatalf("Expected first retry to fit into the budget, got %d", resp.Code)
+	}
+
+	if resp, _ := doRequest(t, h, "/"); resp.Code != http.StatusBadGateway {
+		t.Fatalf("Expected request to fail fast with exhausted budget, got %d", resp.Code)
+	}
+}
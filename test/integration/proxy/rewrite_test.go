@@ -0,0 +1,97 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+
+	"github.com/mk/loadBalancer/internal/proxy"
+)
+
+// pathEcho запускает бэкенд, отвечающий путём, Host и query полученного запроса.
+func pathEcho(t *testing.T, base string) (*httptest.Server, string) {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Upstream-Host", r.Host)
+		w.Write([]byte(r.URL.RequestURI()))
+	}))
+	t.Cleanup(srv.Close)
+	return srv, srv.URL + base
+}
+
+func TestRewriteStripPrefix(t *testing.T) {
+	srv, _ := pathEcho(t, "")
+	router := newRouter(&proxy.Route{
+		PathPrefix: "/api/users",
+		Rewrite:    &proxy.PathRewrite{StripPrefix: "/api/users"},
+		Handler:    newProxy(t, srv),
+	})
+
+	cases := map[string]string{
+		"/api/users/42?full=1": "/42?full=1",
+		"/api/users":           "/",
+		// Префикс снимается только целыми сегментами пути
+		"/api/usersX": "/api/usersX",
+	}
+	for path, want := range cases {
+		if _, body := serve(router, httptest.NewRequest(http.MethodGet, path, nil)); body != want {
+			t.Errorf("%s: expected upstream path %q, got %q", path, want, body)
+		}
+	}
+}
+
+func TestRewriteRegexWithCaptureGroups(t *testing.T) {
+	srv, _ := pathEcho(t, "")
+	router := newRouter(&proxy.Route{
+		PathRegex: regexp.MustCompile(`^/v1/orders/\d+/items$`),
+		Rewrite: &proxy.PathRewrite{
+			Regex:       regexp.MustCompile(`^/v1/orders/(\d+)/items$`),
+			Replacement: "/items/$1",
+		},
+		Handler: newProxy(t, srv),
+	})
+
+	_, body := serve(router, httptest.NewRequest(http.MethodGet, "/v1/orders/17/items?limit=5", nil))
+	if body != "/items/17?limit=5" {
+		t.Fatalf("Expected rewritten path, got %q", body)
+	}
+
+	router = newRouter(&proxy.Route{
+		Rewrite: &proxy.PathRewrite{
+			Regex:       regexp.MustCompile(`^/v1/(?P<svc>\w+)/(.*)$`),
+			Replacement: "/${svc}/api/$2",
+		},
+		Handler: newProxy(t, srv),
+	})
+	if _, body := serve(router, httptest.NewRequest(http.MethodGet, "/v1/orders/17", nil)); body != "/orders/api/17" {
+		t.Fatalf("Expected named group rewrite, got %q", body)
+	}
+}
+
+func TestBackendBasePathIsJoined(t *testing.T) {
+	srv, base := pathEcho(t, "/v2")
+	h := newProxyURLs(t, base)
+
+	req := httptest.NewRequest(http.MethodGet, "/users?id=1", nil)
+	req.Host = "lb.example.com"
+	resp := httptest.NewRecorder()
+	h.ServeHTTP(resp, req)
+
+	if body := resp.Body.String(); body != "/v2/users?id=1" {
+		t.Fatalf("Expected base path to be joined, got %q", body)
+	}
+	if got, want := resp.Header().Get("X-Upstream-Host"), srv.Listener.Addr().String(); got != want {
+		t.Fatalf("Expected upstream Host %q, got %q", want, got)
+	}
+
+	// Снятый префикс склеивается с базовым путём без двойного слэша
+	router := newRouter(&proxy.Route{
+		PathPrefix: "/api",
+		Rewrite:    &proxy.PathRewrite{StripPrefix: "/api"},
+		Handler:    h,
+	})
+	if _, body := serve(router, httptest.NewRequest(http.MethodGet, "/api/users", nil)); body != "/v2/users" {
+		t.Fatalf("Expected stripped path under base path, got %q", body)
+	}
+}
@@ -0,0 +1,108 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/mk/loadBalancer/internal/proxy"
+)
+
+// newRouter регистрирует таблицу маршрутизации в mux.Router.
+func newRouter(routes ...*proxy.Route) *mux.Router {
+	router := mux.NewRouter()
+	proxy.NewRoutingTable(routes).Register(router)
+	return router
+}
+
+// namedPool создаёт обработчик пула из одного бэкенда, отвечающего своим именем.
+func namedPool(t *testing.T, name string) http.Handler {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(name))
+	}))
+	t.Cleanup(srv.Close)
+	return newProxy(t, srv)
+}
+
+func TestRoutingByHostAndPath(t *testing.T) {
+	users, orders, web := namedPool(t, "users"), namedPool(t, "orders"), namedPool(t, "web")
+	router := newRouter(
+		&proxy.Route{Name: "users", PathPrefix: "/api/users", Handler: users},
+		&proxy.Route{Name: "orders", Host: "*.orders.example.com", Handler: orders},
+		&proxy.Route{Name: "web", PathPrefix: "/", Handler: web},
+	)
+
+	cases := []struct {
+		host, path, want string
+	}{
+		{"lb.example.com", "/api/users/42", "users"},
+		{"eu.orders.example.com:8080", "/anything", "orders"},
+		{"orders.example.com", "/anything", "web"},
+		{"lb.example.com", "/index.html", "web"},
+	}
+	for _, tc := range cases {
+		req := httptest.NewRequest(http.MethodGet, tc.path, nil)
+		req.Host = tc.host
+		resp := httptest.NewRecorder()
+		router.ServeHTTP(resp, req)
+		if body := resp.Body.String(); body != tc.want {
+			t.Errorf("%s%s: expected pool %q, got %d %q", tc.host, tc.path, tc.want, resp.Code, body)
+		}
+	}
+}
+
+func TestRoutingByRegexMethodAndHeader(t *testing.T) {
+	reports, writes, beta := namedPool(t, "reports"), namedPool(t, "writes"), namedPool(t, "beta")
+	router := newRouter(
+		&proxy.Route{PathRegex: regexp.MustCompile(`^/reports/\d+$`), Handler: reports},
+		&proxy.Route{Methods: []string{http.MethodPost, http.MethodPut}, Handler: writes},
+		&proxy.Route{Headers: map[string]string{"X-Canary": "beta"}, Handler: beta},
+	)
+
+	req := httptest.NewRequest(http.MethodGet, "/reports/7", nil)
+	if _, body := serve(router, req); body != "reports" {
+		t.Errorf("Expected regex route, got %q", body)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/reports/x", nil)
+	if _, body := serve(router, req); body != "writes" {
+		t.Errorf("Expected method route, got %q", body)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("X-Canary", "beta")
+	if _, body := serve(router, req); body != "beta" {
+		t.Errorf("Expected header route, got %q", body)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/", nil)
+	if code, _ := serve(router, req); code != http.StatusNotFound {
+		t.Errorf("Expected 404 without matching route, got %d", code)
+	}
+}
+
+func TestRoutingPriority(t *testing.T) {
+	low, high, first := namedPool(t, "low"), namedPool(t, "high"), namedPool(t, "first")
+	router := newRouter(
+		&proxy.Route{PathPrefix: "/", Handler: low},
+		&proxy.Route{PathPrefix: "/api", Priority: 10, Handler: first},
+		&proxy.Route{PathPrefix: "/api", Priority: 10, Handler: high},
+	)
+
+	// Выигрывает первое из подходящих правил с наибольшим приоритетом
+	if _, body := serve(router, httptest.NewRequest(http.MethodGet, "/api/x", nil)); body != "first" {
+		t.Errorf("Expected first high-priority route, got %q", body)
+	}
+	if _, body := serve(router, httptest.NewRequest(http.MethodGet, "/other", nil)); body != "low" {
+		t.Errorf("Expected fallback route, got %q", body)
+	}
+}
+
+func serve(h http.Handler, req *http.Request) (int, string) {
+	resp := httptest.NewRecorder()
+	h.ServeHTTP(resp, req)
+	return resp.Code, resp.Body.String()
+}
@@ -0,0 +1,120 @@
+package proxy
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/mk/loadBalancer/internal/proxy"
+)
+
+// stickyCookie возвращает cookie привязки из ответа, если она была выдана.
+func stickyCookie(resp *http.Response, name string) *http.Cookie {
+	for _, c := range resp.Cookies() {
+		if c.Name == name {
+			return c
+		}
+	}
+	return nil
+}
+
+func TestStickySessions(t *testing.T) {
+	servers := startBackends(t, 3)
+	h := newProxy(t, servers...)
+
+	sticky, err := proxy.NewStickySessions("lb", time.Hour, "secret")
+	if err != nil {
+		t.Fatalf("Failed to create sticky sessions: %v", err)
+	}
+	h.Sticky = sticky
+
+	// Первый запрос выдаёт cookie
+	resp, first := doRequest(t, h, "/")
+	cookie := stickyCookie(resp.Result(), "lb")
+	if cookie == nil {
+		t.Fatal("Expected sticky cookie on first response")
+	}
+
+	// Последующие запросы с cookie попадают на тот же бэкенд, и каждый продлевает привязку
+	for i := 0; i < 5; i++ {
+		resp, body := doRequest(t, h, "/", cookie)
+		if body != first {
+			t.Fatalf("Expected request to stick to %s, got %s", first, body)
+		}
+		refreshed := stickyCookie(resp.Result(), "lb")
+		if refreshed == nil || refreshed.MaxAge != int(time.Hour.Seconds()) {
+			t.Fatalf("Expected pinned request to refresh the cookie for the full TTL, got %v", refreshed)
+		}
+		cookie = refreshed
+	}
+
+	// Закреплённый бэкенд умирает: запрос прозрачно уходит на другой и cookie выдаётся заново
+	for i, b := range h.BackendPool.AllBackends() {
+		if fmt.Sprintf("backend-%d", i) == first {
+			b.SetAlive(false)
+		}
+	}
+
+	resp, body := doRequest(t, h, "/", cookie)
+	if body == first || resp.Code != http.StatusOK {
+		t.Fatalf("Expected failover to another backend, got %d %s", resp.Code, body)
+	}
+	reissued := stickyCookie(resp.Result(), "lb")
+	if reissued == nil {
+		t.Fatal("Expected sticky cookie to be re-issued after failover")
+	}
+
+	_, again := doRequest(t, h, "/", reissued)
+	if again != body {
+		t.Errorf("Expected re-issued cookie to pin %s, got %s", body, again)
+	}
+}
+
+func TestStickySessionsRejectsTamperedCookie(t *testing.T) {
+	servers := startBackends(t, 2)
+	h := newProxy(t, servers...)
+	h.Sticky, _ = proxy.NewStickySessions("lb", time.Hour, "secret")
+
+	resp, _ := doRequest(t, h, "/")
+	cookie := stickyCookie(resp.Result(), "lb")
+	cookie.Value = "0000000000000000" + cookie.Value[16:]
+
+	resp, _ = doRequest(t, h, "/", cookie)
+	if stickyCookie(resp.Result(), "lb") == nil {
+		t.Error("Expected tampered cookie to be ignored and a new one issued")
+	}
+}
+
+func TestStickySessionsPerPool(t *testing.T) {
+	sticky, err := proxy.NewStickySessions("lb", time.Hour, "secret")
+	if err != nil {
+		t.Fatalf("Failed to create sticky sessions: %v", err)
+	}
+	users, orders := newProxy(t, startBackends(t, 3)...), newProxy(t, startBackends(t, 3)...)
+	users.Sticky, orders.Sticky = sticky.ForPool("users"), sticky.ForPool("orders")
+	router := newRouter(
+		&proxy.Route{PathPrefix: "/users", Handler: users},
+		&proxy.Route{PathPrefix: "/orders", Handler: orders},
+	)
+
+	// Клиент чередует маршруты: cookie пулов не затирают друг друга
+	resp, firstUsers := doRequest(t, router, "/users")
+	usersCookie := stickyCookie(resp.Result(), "lb_users")
+	resp, firstOrders := doRequest(t, router, "/orders")
+	ordersCookie := stickyCookie(resp.Result(), "lb_orders")
+	if usersCookie == nil || ordersCookie == nil {
+		t.Fatal("Expected each pool to issue its own cookie")
+	}
+	// Запрос продлевает только cookie своего пула
+	for i := 0; i < 4; i++ {
+		resp, body := doRequest(t, router, "/users", usersCookie, ordersCookie)
+		if cookies := resp.Result().Cookies(); body != firstUsers || len(cookies) != 1 || cookies[0].Name != "lb_users" {
+			t.Fatalf("Expected users request to stick to %s and refresh only its cookie, got %s", firstUsers, body)
+		}
+		resp, body = doRequest(t, router, "/orders", usersCookie, ordersCookie)
+		if cookies := resp.Result().Cookies(); body != firstOrders || len(cookies) != 1 || cookies[0].Name != "lb_orders" {
+			t.Fatalf("Expected orders request to stick to %s and refresh only its cookie, got %s", firstOrders, body)
+		}
+	}
+}
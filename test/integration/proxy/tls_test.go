@@ -0,0 +1,134 @@
+package proxy
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"testing"
+
+	"github.com/mk/loadBalancer/internal/certs"
+	"github.com/mk/loadBalancer/internal/certs/certstest"
+	"go.uber.org/zap"
+)
+
+// startTLSProxy запускает прокси за TLS-листенером с сертификатами из store.
+func startTLSProxy(t *testing.T, store *certs.Store, h http.Handler) string {
+	t.Helper()
+	cfg, err := certs.ServerConfig(store, "1.2", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := &http.Server{Handler: h}
+	go srv.Serve(tls.NewListener(ln, cfg))
+	t.Cleanup(func() { srv.Close() })
+	return ln.Addr().String()
+}
+
+// tlsGet выполняет запрос по HTTPS с заданным SNI и возвращает ответ и имя
+// сертификата, который предъявил сервер.
+func tlsGet(t *testing.T, addr, serverName string) (*http.Response, string) {
+	t.Helper()
+	cfg := &tls.Config{ServerName: serverName, InsecureSkipVerify: true}
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: cfg}}
+	resp, err := client.Get(fmt.Sprintf("https://%s/", addr))
+	if err != nil {
+		t.Fatalf("GET %s: %v", serverName, err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	return resp, resp.TLS.PeerCertificates[0].Subject.CommonName
+}
+
+func TestTLSTerminationSelectsCertificateBySNI(t *testing.T) {
+	dir := t.TempDir()
+	store, err := certs.NewStore([]certs.Pair{
+		certstest.WriteCert(t, dir, "default", "lb.example.com"),
+		certstest.WriteCert(t, dir, "api", "api.example.org"),
+	}, zap.NewNop().Sugar())
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := startTLSProxy(t, store, newProxy(t, headerEcho(t)))
+
+	resp, name := tlsGet(t, addr, "api.example.org")
+	if name != "api.example.org" {
+		t.Fatalf("Expected api.example.org certificate, got %s", name)
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", resp.StatusCode)
+	}
+	if _, name := tlsGet(t, addr, "other.example.net"); name != "lb.example.com" {
+		t.Fatalf("Expected default certificate, got %s", name)
+	}
+}
+
+func TestTLSTerminationForwardsHTTPS(t *testing.T) {
+	dir := t.TempDir()
+	store, err := certs.NewStore([]certs.Pair{certstest.WriteCert(t, dir, "default", "lb.example.com")}, zap.NewNop().Sugar())
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := startTLSProxy(t, store, newProxy(t, headerEcho(t)))
+
+	resp, _ := tlsGet(t, addr, "lb.example.com")
+	var upstream http.Header
+	if err := json.NewDecoder(resp.Body).Decode(&upstream); err != nil {
+		t.Fatalf("decode upstream headers: %v", err)
+	}
+	if got := upstream.Get("X-Forwarded-Proto"); got != "https" {
+		t.Fatalf("Expected X-Forwarded-Proto https, got %q", got)
+	}
+}
+
+func TestTLSTerminationMinVersion(t *testing.T) {
+	dir := t.TempDir()
+	store, err := certs.NewStore([]certs.Pair{certstest.WriteCert(t, dir, "default", "lb.example.com")}, zap.NewNop().Sugar())
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := certs.ServerConfig(store, "1.3", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := &http.Server{Handler: newProxy(t, headerEcho(t))}
+	go srv.Serve(tls.NewListener(ln, cfg))
+	t.Cleanup(func() { srv.Close() })
+
+	conn, err := tls.Dial("tcp", ln.Addr().String(), &tls.Config{
+		ServerName: "lb.example.com", InsecureSkipVerify: true, MaxVersion: tls.VersionTLS12,
+	})
+	if err == nil {
+		conn.Close()
+		t.Fatal("Expected TLS 1.2 handshake to be rejected")
+	}
+}
+
+func TestTLSCertificateHotReload(t *testing.T) {
+	dir := t.TempDir()
+	store, err := certs.NewStore([]certs.Pair{certstest.WriteCert(t, dir, "site", "old.example.com")}, zap.NewNop().Sugar())
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := startTLSProxy(t, store, newProxy(t, headerEcho(t)))
+	if _, name := tlsGet(t, addr, "old.example.com"); name != "old.example.com" {
+		t.Fatalf("Expected old certificate, got %s", name)
+	}
+
+	certstest.WriteCert(t, dir, "site", "new.example.com")
+	if changed, err := store.Reload(); !changed || err != nil {
+		t.Fatalf("Expected reload, got %v, %v", changed, err)
+	}
+	// Новые соединения получают новый сертификат без перезапуска листенера
+	if _, name := tlsGet(t, addr, "new.example.com"); name != "new.example.com" {
+		t.Fatalf("Expected new certificate, got %s", name)
+	}
+}
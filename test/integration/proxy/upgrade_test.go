@@ -0,0 +1,215 @@
+package proxy
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mk/loadBalancer/internal/proxy"
+	"go.uber.org/zap"
+)
+
+// echoUpgradeBackend переключается на протокол "echo" и возвращает клиенту каждую строку.
+func echoUpgradeBackend(t *testing.T) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Upgrade") != "echo" {
+			http.Error(w, "upgrade required", http.StatusUpgradeRequired)
+			return
+		}
+		conn, brw, err := http.NewResponseController(w).Hijack()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		brw.WriteString("HTTP/1.1 101 Switching Protocols\r\nUpgrade: echo\r\nConnection: Upgrade\r\n\r\n")
+		brw.Flush()
+		for {
+			line, err := brw.ReadString('\n')
+			if err != nil {
+				return
+			}
+			brw.WriteString(line)
+			brw.Flush()
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// openTunnel открывает через прокси соединение с протоколом "echo".
+func openTunnel(t *testing.T, proxyURL string) (net.Conn, *bufio.Reader) {
+	t.Helper()
+	conn, err := net.Dial("tcp", strings.TrimPrefix(proxyURL, "http://"))
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	req, _ := http.NewRequest(http.MethodGet, proxyURL+"/ws", nil)
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "echo")
+	if err := req.Write(conn); err != nil {
+		t.Fatalf("write request: %v", err)
+	}
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, req)
+	if err != nil {
+		t.Fatalf("read response: %v", err)
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("Expected 101, got %d", resp.StatusCode)
+	}
+	return conn, br
+}
+
+func echo(t *testing.T, conn net.Conn, br *bufio.Reader, msg string) {
+	t.Helper()
+	conn.SetDeadline(time.Now().Add(2 * time.Second))
+	if _, err := io.WriteString(conn, msg+"\n"); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	line, err := br.ReadString('\n')
+	if err != nil || line != msg+"\n" {
+		t.Fatalf("Expected echo %q, got %q (%v)", msg, line, err)
+	}
+}
+
+// waitFor ждёт выполнения условия до секунды.
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("Timed out waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// expectClosed проверяет, что балансировщик закрыл туннель.
+func expectClosed(t *testing.T, conn net.Conn, br *bufio.Reader) {
+	t.Helper()
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	if _, err := br.ReadString('\n'); err != io.EOF {
+		t.Fatalf("Expected tunnel to be closed, got %v", err)
+	}
+}
+
+func newTunnelProxy(t *testing.T, tunnels *proxy.Tunnels) (*proxy.ProxyHandler, *httptest.Server) {
+	t.Helper()
+	h := newProxy(t, echoUpgradeBackend(t))
+	h.Tunnels = tunnels
+	srv := httptest.NewUnstartedServer(h)
+	// Таймауты сервера не должны обрывать долгоживущие туннели
+	srv.Config.ReadTimeout = 100 * time.Millisecond
+	srv.Config.WriteTimeout = 100 * time.Millisecond
+	srv.Start()
+	t.Cleanup(srv.Close)
+	return h, srv
+}
+
+func TestUpgradeTunnelOutlivesServerTimeouts(t *testing.T) {
+	tunnels := proxy.NewTunnels(0, 0, zap.NewNop().Sugar())
+	h, srv := newTunnelProxy(t, tunnels)
+	backend := h.BackendPool.AllBackends()[0]
+
+	conn, br := openTunnel(t, srv.URL)
+	echo(t, conn, br, "hello")
+	time.Sleep(300 * time.Millisecond)
+	echo(t, conn, br, "still here")
+
+	// Туннель учитывается как активное соединение бэкенда, пока открыт
+	if got := backend.GetConnections(); got != 1 {
+		t.Fatalf("Expected 1 active connection during tunnel, got %d", got)
+	}
+	if got := tunnels.Active(); got != 1 {
+		t.Fatalf("Expected 1 active tunnel, got %d", got)
+	}
+
+	conn.Close()
+	waitFor(t, "tunnel release", func() bool { return backend.GetConnections() == 0 && tunnels.Active() == 0 })
+}
+
+func TestUpgradeTunnelIdleTimeout(t *testing.T) {
+	tunnels := proxy.NewTunnels(150*time.Millisecond, 0, zap.NewNop().Sugar())
+	_, srv := newTunnelProxy(t, tunnels)
+
+	conn, br := openTunnel(t, srv.URL)
+	// Трафик продлевает туннель
+	for i := 0; i < 3; i++ {
+		time.Sleep(80 * time.Millisecond)
+		echo(t, conn, br, "ping")
+	}
+	expectClosed(t, conn, br)
+	waitFor(t, "tunnel release", func() bool { return tunnels.Active() == 0 })
+}
+
+func TestUpgradeTunnelMaxLifetime(t *testing.T) {
+	tunnels := proxy.NewTunnels(0, 200*time.Millisecond, zap.NewNop().Sugar())
+	_, srv := newTunnelProxy(t, tunnels)
+
+	conn, br := openTunnel(t, srv.URL)
+	echo(t, conn, br, "ping")
+	expectClosed(t, conn, br)
+}
+
+func TestUpgradeTunnelsClosedOnShutdown(t *testing.T) {
+	tunnels := proxy.NewTunnels(0, 0, zap.NewNop().Sugar())
+	h, srv := newTunnelProxy(t, tunnels)
+
+	conn, br := openTunnel(t, srv.URL)
+	echo(t, conn, br, "ping")
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := tunnels.Shutdown(ctx); err != nil {
+		t.Fatalf("Shutdown: %v", err)
+	}
+	expectClosed(t, conn, br)
+	waitFor(t, "connection release", func() bool { return h.BackendPool.AllBackends()[0].GetConnections() == 0 })
+}
+
+func TestUpgradeTunnelOutlivesTotalTimeout(t *testing.T) {
+	h, srv := newTunnelProxy(t, proxy.NewTunnels(0, 0, zap.NewNop().Sugar()))
+	h.Retry = proxy.RetryPolicy{TotalTimeout: 100 * time.Millisecond}
+
+	conn, br := openTunnel(t, srv.URL)
+	echo(t, conn, br, "hello")
+	time.Sleep(300 * time.Millisecond)
+	echo(t, conn, br, "still here")
+}
+
+func TestUpgradeHeaderDoesNotBypassTotalTimeout(t *testing.T) {
+	// Бэкенд не отвечает на запрос со сменой протокола
+	stalled := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(2 * time.Second):
+		}
+	}))
+	t.Cleanup(stalled.Close)
+
+	h := newProxy(t, stalled)
+	h.Retry = proxy.RetryPolicy{TotalTimeout: 100 * time.Millisecond}
+
+	req := httptest.NewRequest(http.MethodGet, "/ws", nil)
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "echo")
+	rec := httptest.NewRecorder()
+	start := time.Now()
+	h.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusGatewayTimeout {
+		t.Fatalf("Expected 504 before the backend switched protocols, got %d", rec.Code)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Total timeout was not applied, request took %v", elapsed)
+	}
+}
@@ -0,0 +1,183 @@
+package proxy
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/mk/loadBalancer/internal/balancer"
+	"github.com/mk/loadBalancer/internal/certs"
+	"github.com/mk/loadBalancer/internal/certs/certstest"
+	"github.com/mk/loadBalancer/internal/proxy"
+	"go.uber.org/zap"
+)
+
+// tlsBackend запускает HTTPS-бэкенд, который отвечает CN клиентского
+// сертификата (или "anonymous") и отвечает 200 на /healthz.
+func tlsBackend(t *testing.T, configure func(*tls.Config)) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if len(r.TLS.PeerCertificates) > 0 {
+			fmt.Fprint(w, r.TLS.PeerCertificates[0].Subject.CommonName)
+			return
+		}
+		fmt.Fprint(w, "anonymous")
+	}))
+	srv.TLS = &tls.Config{}
+	if configure != nil {
+		configure(srv.TLS)
+	}
+	srv.StartTLS()
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// writeCA сохраняет сертификат тестового бэкенда как PEM-бандл.
+func writeCA(t *testing.T, srv *httptest.Server) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "ca.pem")
+	data := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw})
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// upstreamTLSProxy создаёт прокси к srv с TLS-настройками пула.
+func upstreamTLSProxy(t *testing.T, srv *httptest.Server, cfg *tls.Config) *proxy.ProxyHandler {
+	t.Helper()
+	h := newProxy(t, srv)
+	transport := proxy.DefaultTransportConfig()
+	transport.TLS = cfg
+	h.Transport = proxy.NewTransport(transport)
+	return h
+}
+
+func clientConfig(t *testing.T, caFile, serverName string, client *certs.Store, insecure bool) *tls.Config {
+	t.Helper()
+	cfg, err := certs.ClientConfig(caFile, serverName, client, insecure)
+	if err != nil {
+		t.Fatalf("ClientConfig: %v", err)
+	}
+	return cfg
+}
+
+func TestUpstreamTLSVerifiesBackendCertificate(t *testing.T) {
+	srv := tlsBackend(t, nil)
+	ca := writeCA(t, srv)
+
+	resp, body := doRequest(t, upstreamTLSProxy(t, srv, clientConfig(t, ca, "", nil, false)), "/")
+	if resp.Code != http.StatusOK || body != "anonymous" {
+		t.Fatalf("Expected 200 from trusted backend, got %d %q", resp.Code, body)
+	}
+
+	// Без CA сертификат бэкенда не проходит проверку системными корневыми
+	resp, _ = doRequest(t, upstreamTLSProxy(t, srv, nil), "/")
+	if resp.Code != http.StatusBadGateway {
+		t.Fatalf("Expected 502 for untrusted backend, got %d", resp.Code)
+	}
+}
+
+func TestUpstreamTLSServerNameOverride(t *testing.T) {
+	srv := tlsBackend(t, nil)
+	ca := writeCA(t, srv)
+
+	// Сертификат httptest выписан на example.com
+	resp, _ := doRequest(t, upstreamTLSProxy(t, srv, clientConfig(t, ca, "example.com", nil, false)), "/")
+	if resp.Code != http.StatusOK {
+		t.Fatalf("Expected 200 with matching server_name, got %d", resp.Code)
+	}
+	resp, _ = doRequest(t, upstreamTLSProxy(t, srv, clientConfig(t, ca, "backend.internal", nil, false)), "/")
+	if resp.Code != http.StatusBadGateway {
+		t.Fatalf("Expected 502 with mismatching server_name, got %d", resp.Code)
+	}
+}
+
+func TestUpstreamTLSInsecureSkipVerify(t *testing.T) {
+	srv := tlsBackend(t, nil)
+
+	resp, _ := doRequest(t, upstreamTLSProxy(t, srv, clientConfig(t, "", "", nil, true)), "/")
+	if resp.Code != http.StatusOK {
+		t.Fatalf("Expected 200 with insecure_skip_verify, got %d", resp.Code)
+	}
+}
+
+func TestUpstreamMutualTLS(t *testing.T) {
+	clientPair := certstest.WriteCert(t, t.TempDir(), "client", "lb-client")
+	clientCA, err := certs.LoadCAPool(clientPair.CertFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := tlsBackend(t, func(cfg *tls.Config) {
+		cfg.ClientAuth = tls.RequireAndVerifyClientCert
+		cfg.ClientCAs = clientCA
+	})
+	ca := writeCA(t, srv)
+
+	client, err := certs.NewStore([]certs.Pair{clientPair}, zap.NewNop().Sugar())
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, body := doRequest(t, upstreamTLSProxy(t, srv, clientConfig(t, ca, "", client, false)), "/")
+	if resp.Code != http.StatusOK || body != "lb-client" {
+		t.Fatalf("Expected backend to see client certificate, got %d %q", resp.Code, body)
+	}
+
+	resp, _ = doRequest(t, upstreamTLSProxy(t, srv, clientConfig(t, ca, "", nil, false)), "/")
+	if resp.Code != http.StatusBadGateway {
+		t.Fatalf("Expected 502 without client certificate, got %d", resp.Code)
+	}
+}
+
+func TestUpstreamTLSHealthChecks(t *testing.T) {
+	clientPair := certstest.WriteCert(t, t.TempDir(), "client", "lb-client")
+	clientCA, err := certs.LoadCAPool(clientPair.CertFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := tlsBackend(t, func(cfg *tls.Config) {
+		cfg.ClientAuth = tls.RequireAndVerifyClientCert
+		cfg.ClientCAs = clientCA
+	})
+	client, err := certs.NewStore([]certs.Pair{clientPair}, zap.NewNop().Sugar())
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := proxy.DefaultTransportConfig()
+	cfg.TLS = clientConfig(t, writeCA(t, srv), "", client, false)
+
+	check := func(transport http.RoundTripper) balancer.HealthStatus {
+		backend := balancer.NewBackend(srv.URL)
+		checker := balancer.NewChecker([]*balancer.Backend{backend}, time.Hour)
+		if transport != nil {
+			checker.Client.Transport = transport
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go checker.Run(ctx)
+		deadline := time.Now().Add(2 * time.Second)
+		for time.Now().Before(deadline) {
+			if status := backend.HealthStatus(); len(status.History) > 0 {
+				return status
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		t.Fatal("Timed out waiting for health check")
+		return balancer.HealthStatus{}
+	}
+
+	// Health check идёт через транспорт пула с тем же CA и клиентским сертификатом
+	if status := check(proxy.NewTransport(cfg)); status.ConsecutiveSuccesses != 1 {
+		t.Fatalf("Expected successful health check over mTLS, got %+v", status)
+	}
+	if status := check(nil); status.ConsecutiveFailures != 1 {
+		t.Fatalf("Expected failed health check without pool TLS, got %+v", status)
+	}
+}
@@ -1,11 +1,18 @@
 package ratelimiter
 
 import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
 	"fmt"
 	"log"
+	"net/http"
+	"net/http/httptest"
 	"testing"
 	"time"
 
+	"github.com/mk/loadBalancer/internal/certs"
+	"github.com/mk/loadBalancer/internal/clientip"
 	"github.com/mk/loadBalancer/internal/ratelimiter"
 	"github.com/mk/loadBalancer/internal/storage"
 	"go.uber.org/zap"
@@ -81,3 +88,103 @@ func TestRateLimiter_MultipleClients(t *testing.T) {
 	}
 }
 
+
+func TestRateLimitMiddlewareIgnoresSpoofedForwardedFor(t *testing.T) {
+	rl := setupTestRateLimiter(2, 0)
+	handler := ratelimiter.RateLimitMiddleware(rl, zap.NewNop().Sugar())(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	// Клиент без доверенного прокси меняет X-Forwarded-For, но лимит считается по адресу соединения
+	for i := 0; i < 3; i++ {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.RemoteAddr = "203.0.113.5:1234"
+		req.Header.Set("X-Forwarded-For", fmt.Sprintf("1.1.1.%d", i))
+		req.Header.Set("X-Real-IP", fmt.Sprintf("2.2.2.%d", i))
+		resp := httptest.NewRecorder()
+		handler.ServeHTTP(resp, req)
+
+		want := http.StatusOK
+		if i == 2 {
+			want = http.StatusTooManyRequests
+		}
+		if resp.Code != want {
+			t.Fatalf("Request %d: expected %d, got %d", i, want, resp.Code)
+		}
+	}
+}
+
+func TestRateLimitMiddlewareUsesTrustedProxyChain(t *testing.T) {
+	rl := setupTestRateLimiter(1, 0)
+	resolver, err := clientip.NewResolver([]string{"10.0.0.0/8"})
+	if err != nil {
+		t.Fatalf("NewResolver: %v", err)
+	}
+	rl.SetClientIPResolver(resolver)
+	handler := ratelimiter.RateLimitMiddleware(rl, zap.NewNop().Sugar())(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	// За доверенным прокси разные клиенты получают разные лимиты
+	for i := 0; i < 3; i++ {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.RemoteAddr = "10.0.0.2:1234"
+		req.Header.Set("X-Forwarded-For", fmt.Sprintf("198.51.100.%d", i))
+		resp := httptest.NewRecorder()
+		handler.ServeHTTP(resp, req)
+		if resp.Code != http.StatusOK {
+			t.Fatalf("Request %d: expected 200, got %d", i, resp.Code)
+		}
+	}
+}
+
+func TestRateLimitMiddlewareUsesClientCertificateIdentity(t *testing.T) {
+	rl := setupTestRateLimiter(1, 0)
+	rl.SetClientIdentity(func(r *http.Request) string {
+		return certs.ClientIdentity(r, certs.FieldCN)
+	})
+	handler := ratelimiter.RateLimitMiddleware(rl, zap.NewNop().Sugar())(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	billing := &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{{Subject: pkix.Name{CommonName: "billing"}}}}}
+	cases := []struct {
+		state    *tls.ConnectionState
+		clientID string
+		want     int
+	}{
+		{billing, "a", http.StatusOK},
+		// X-Client-ID не влияет: лимит общий для сертификата
+		{billing, "b", http.StatusTooManyRequests},
+		// Без сертификата X-Client-ID не позволяет занять чужой лимит — считается IP
+		{nil, "billing", http.StatusOK},
+	}
+	for i, tc := range cases {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.RemoteAddr = "203.0.113.5:1234"
+		req.TLS = tc.state
+		req.Header.Set("X-Client-ID", tc.clientID)
+		resp := httptest.NewRecorder()
+		handler.ServeHTTP(resp, req)
+		if resp.Code != tc.want {
+			t.Fatalf("Request %d: expected %d, got %d", i, tc.want, resp.Code)
+		}
+	}
+}
+
+func TestRateLimitMiddlewareGRPCStatus(t *testing.T) {
+	rl := setupTestRateLimiter(1, 0)
+	handler := ratelimiter.RateLimitMiddleware(rl, zap.NewNop().Sugar())(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	var resp *httptest.ResponseRecorder
+	for i := 0; i < 2; i++ {
+		req := httptest.NewRequest(http.MethodPost, "/grpc.health.v1.Health/Check", nil)
+		req.RemoteAddr = "203.0.113.7:1234"
+		req.Header.Set("Content-Type", "application/grpc")
+		resp = httptest.NewRecorder()
+		handler.ServeHTTP(resp, req)
+	}
+
+	// Клиент gRPC получает RESOURCE_EXHAUSTED в трейлерах, а не HTTP 429
+	result := resp.Result()
+	if result.StatusCode != http.StatusOK {
+		t.Fatalf("Expected HTTP 200 for gRPC, got %d", result.StatusCode)
+	}
+	if got := result.Trailer.Get("Grpc-Status"); got != "8" {
+		t.Fatalf("Expected grpc-status 8 (RESOURCE_EXHAUSTED), got %q", got)
+	}
+}
@@ -0,0 +1,82 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/mk/loadBalancer/internal/config"
+	"github.com/mk/loadBalancer/internal/server"
+)
+
+// healthCountingBackend считает health checks, полученные бэкендом.
+func healthCountingBackend(t *testing.T, hits *atomic.Int32) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/healthz" {
+			hits.Add(1)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// loadConfig записывает конфигурацию во временный файл и загружает её.
+func loadConfig(t *testing.T, yaml string) *config.Config {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(yaml), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := config.Load(path)
+	if err != nil {
+		t.Fatalf("config.Load: %v", err)
+	}
+	return cfg
+}
+
+func TestServerRunsHealthCheckerUntilShutdown(t *testing.T) {
+	var hits atomic.Int32
+	backend := healthCountingBackend(t, &hits)
+	cfg := loadConfig(t, `
+port: 0
+databasePath: ":memory:"
+strategy: round_robin
+backends: ["`+backend.URL+`"]
+health_check:
+  interval: 20ms
+`)
+
+	srv, err := server.New(cfg)
+	if err != nil {
+		t.Fatalf("server.New: %v", err)
+	}
+
+	// Health checker запускается вместе с сервером, без вызова Start
+	deadline := time.Now().Add(2 * time.Second)
+	for hits.Load() < 3 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if got := hits.Load(); got < 3 {
+		t.Fatalf("Expected periodic health checks after server.New, got %d", got)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := srv.Shutdown(ctx); err != nil {
+		t.Fatalf("Shutdown: %v", err)
+	}
+
+	// Проверка, начатая до Shutdown, ещё может завершиться; новых быть не должно
+	time.Sleep(30 * time.Millisecond)
+	stopped := hits.Load()
+	time.Sleep(100 * time.Millisecond)
+	if got := hits.Load(); got != stopped {
+		t.Fatalf("Expected health checks to stop after Shutdown, got %d more", got-stopped)
+	}
+}